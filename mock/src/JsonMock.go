@@ -52,106 +52,223 @@ note bottom of [Client apps] : Could be REAL or FAKE apps
 package main
 
 import (
-	"bytes"
-	"encoding/json"
-	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
-	"net/http/fcgi"
 	"os"
 	"path/filepath"
-	"regexp"
 	"sort"
-	"strconv"
-	"strings"
+	"time"
 
-	"github.com/gorilla/mux"
-	"github.com/xeipuuv/gojsonschema"
+	"github.com/xue2sheng/postJsonTest/mock/src/jsonmock"
 )
 
-type QueryResponse struct {
-	query    string
-	response string
-}
-
-// Request Response map
-type RequestResponseMap map[string]QueryResponse
-
-// helper for HTTP handler queries
-type customHandler struct {
-	cmux        http.Handler
-	rrmap       *RequestResponseMap
-	reqJS       *gojsonschema.JSONLoader
-	forcedDebug bool
-}
-
-// Expected data Dir
-var DataDir = "data"
-
-// RequestJsonSchema to validate requests
-var RequestJsonSchemaFile = "requestJsonSchema.json"
+func main() {
 
-// ResponseJsonSchema to validate responses
-var ResponseJsonSchemaFile = "responseJsonSchema.json"
+	// subcommands, no listener at all
+	if len(os.Args) > 1 && os.Args[1] == ValidateCommand {
+		os.Exit(validateCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == ImportCommand {
+		os.Exit(importCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == ExportCommand {
+		os.Exit(exportCommand(os.Args[2:]))
+	}
 
-// MockRequestResponseFile global var due to lazyness
-var MockRequestResponseFile = "requestResponseMap.json"
+	options := cmdLine()
+	config := options.Config
 
-// DebugParameter global var due to lazyness
-var DebugParameter = "debug"
-var ForcedDebug = false
+	// logs appended to a file, flushed before exiting
+	var logFile *os.File
+	if len(options.LogFile) > 0 {
+		var err error
+		logFile, err = os.OpenFile(options.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.SetOutput(logFile)
+	}
 
-func main() {
+	log.Printf("Launched "+os.Args[0]+" -host="+options.Host+" -port="+options.Port+" -map="+config.MapFile+
+		" -req="+config.RequestSchemaFile+" -res="+config.ResponseSchemaFile+" -schemas="+config.SchemaDir+" -strict=%t -report="+options.ReportFile+
+		" -debug=%t -debugParameter="+config.DebugParameter+" -log="+options.LogFile+" -drain=%v", config.Strict, config.ForcedDebug, options.DrainTimeout)
+
+	// a single mock server, or several isolated ones told apart by path prefix
+	var serve func(net.Listener) error
+	var dashboardEndpoints []jsonmock.Endpoint
+	if len(options.Endpoints) == 0 {
+		server := newServer("", config, options.ReportFile)
+		serve = func(listener net.Listener) error { return server.ServeFCGI(listener, options.DrainTimeout) }
+		dashboardEndpoints = []jsonmock.Endpoint{{Prefix: "/", Server: server}}
+	} else {
+		endpoints := jsonmock.NewEndpointMux()
+		for _, endpoint := range options.Endpoints {
+			log.Printf("Endpoint "+endpoint.Name+" at "+endpoint.Prefix+" -map="+endpoint.Config.MapFile+" -req="+endpoint.Config.RequestSchemaFile+
+				" -res="+endpoint.Config.ResponseSchemaFile+" -strict=%t -generate="+endpoint.Config.Generate+" -bidder="+endpoint.Config.BidderConfigFile, endpoint.Config.Strict)
+			server := newServer(endpoint.Name, endpoint.Config, endpoint.ReportFile)
+			if err := endpoints.Add(endpoint.Name, endpoint.Prefix, server); err != nil {
+				log.Fatal(err)
+			}
+		}
+		serve = func(listener net.Listener) error { return endpoints.ServeFCGI(listener, options.DrainTimeout) }
+		dashboardEndpoints = endpoints.Endpoints()
+	}
 
-	host, port, mockRequestResponseFile, requestJsonSchemaFile, responseJsonSchemaFile, forcedDebug := cmdLine()
-	log.Printf("Launched "+os.Args[0]+" -host="+host+" -port="+port+" -map="+mockRequestResponseFile+
-		" -req="+requestJsonSchemaFile+" -res="+responseJsonSchemaFile+" -debug=%t", forcedDebug)
+	// web dashboard on its own plain HTTP listener, closed at shutdown before any other hook, as saving snapshots
+	if len(options.Admin) > 0 {
+		adminListener, err := net.Listen("tcp", options.Admin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Dashboard at http://" + adminListener.Addr().String() + "/")
+		admin := &http.Server{Handler: jsonmock.NewDashboard(dashboardEndpoints)}
+		for _, endpoint := range dashboardEndpoints {
+			endpoint.Server.AtShutdown(func() { admin.Close() })
+		}
+		go func() {
+			if err := admin.Serve(adminListener); err != http.ErrServerClosed {
+				log.Println(err)
+			}
+		}()
+	}
 
-	reqresmap, reqJS, err := validateMockRequestResponseFile(mockRequestResponseFile, requestJsonSchemaFile, responseJsonSchemaFile, forcedDebug)
+	listener, err := net.Listen("tcp", options.Host+":"+options.Port) // see nginx.conf
 	if err != nil {
 		log.Fatal(err)
 	}
-	log.Printf("Number of fake request/response: %d", len(reqresmap))
+	if err := serve(listener); err != nil {
+		log.Fatal(err)
+	}
+	if logFile != nil {
+		logFile.Sync()
+	}
+	log.Println("Shut down " + os.Args[0])
+}
 
-	mux := mux.NewRouter()
-	// bind cmux to mx(route) and rrmap to reqresmap
-	fcgiHandler := &customHandler{cmux: mux, rrmap: &reqresmap, reqJS: &reqJS, forcedDebug: forcedDebug}
-	mux.Path("/").Handler(fcgiHandler)
+// validate the map and report it, exiting when it cannot be served
+func newServer(endpoint string, config jsonmock.Config, reportFile string) *jsonmock.Server {
 
-	listener, _ := net.Listen("tcp", host+":"+port) // see nginx.conf
-	if err := fcgi.Serve(listener, fcgiHandler); err != nil {
+	server, err := jsonmock.NewServer(config)
+	report := jsonmock.ValidationReport{File: config.MapFile}
+	if err == nil {
+		report = server.Report()
+	} else if validationErr, ok := err.(*jsonmock.ValidationError); ok {
+		// even a map that cannot be served gets reported
+		report = validationErr.Report
+	}
+	jsonmock.LogReport(report)
+	if len(reportFile) > 0 {
+		if err := jsonmock.WriteReport(report, reportFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err != nil {
+		if len(endpoint) > 0 {
+			log.Fatal("Endpoint " + endpoint + ": " + err.Error())
+		}
 		log.Fatal(err)
 	}
+	log.Printf("Number of fake request/response: %d", report.Valid)
+	return server
 }
 
-// get command line parameters
-func cmdLine() (string, string, string, string, string, bool) {
+// Command line options of the mock server on top of the ones of the jsonmock package
+type Options struct {
+	Host         string
+	Port         string
+	ReportFile   string
+	LogFile      string
+	DrainTimeout time.Duration
+	Admin        string
+	Config       jsonmock.Config
+	Endpoints    []EndpointOptions
+}
+
+// Options of a named endpoint, by default the ones of the mock server
+type EndpointOptions struct {
+	Name       string
+	Prefix     string
+	ReportFile string
+	Config     jsonmock.Config
+}
 
-	hostArg := "0.0.0.0"
-	portArg := "9797"
-	mockRequestResponseFile := filepath.Dir(os.Args[0]) + filepath.FromSlash("/") + DataDir + filepath.FromSlash("/") + MockRequestResponseFile
-	requestJsonSchemaFile := filepath.Dir(os.Args[0]) + filepath.FromSlash("/") + DataDir + filepath.FromSlash("/") + RequestJsonSchemaFile
-	responseJsonSchemaFile := filepath.Dir(os.Args[0]) + filepath.FromSlash("/") + DataDir + filepath.FromSlash("/") + ResponseJsonSchemaFile
-	forcedDebug := ForcedDebug
+// get command line parameters, environment variables and configuration file options
+func cmdLine() Options {
+
+	options := Options{
+		Host:         "0.0.0.0",
+		Port:         "9797",
+		DrainTimeout: jsonmock.DefaultDrainTimeout,
+		Config: jsonmock.Config{
+			MapFile:            defaultDataFile(jsonmock.DefaultMapFile),
+			RequestSchemaFile:  defaultDataFile(jsonmock.DefaultRequestSchemaFile),
+			ResponseSchemaFile: defaultDataFile(jsonmock.DefaultResponseSchemaFile),
+			DebugParameter:     jsonmock.DefaultDebugParameter,
+			Generate:           jsonmock.GenerateOff,
+			Seed:               1,
+			RateScope:          jsonmock.RateScopeGlobal,
+			MaxBodySize:        jsonmock.DefaultMaxBodySize,
+			Location:           "/testingEnd",
+		},
+	}
+	config := &options.Config
 
-	cmd := strings.Join(os.Args, " ")
-	if strings.Contains(cmd, " help") || strings.Contains(cmd, " -help") || strings.Contains(cmd, " --help") ||
-		strings.Contains(cmd, " -h") || strings.Contains(cmd, " /?") {
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Println()
+		fmt.Println("Usage: " + os.Args[0] + " -config=<ConfigFile> -profile=<Profile> -host=<host> -port=<port> -location=<Location> -map=<MockRequestResponseFile> -req=<RequestJsonSchema> -res=<ResponseJsonSchema> -schemas=<SchemaDir> -strict=<Strict> -report=<ReportFile> -debug=<ForcedDebug> -debugParameter=<DebugParameter> -log=<LogFile> -drain=<DrainTimeout> -admin=<AdminAddress> -generate=<GenerateMode> -seed=<GenerateSeed> -bidder=<BidderConfigFile> -callbacks=<Callbacks> -callbackDelay=<CallbackDelay> -callbackProbability=<CallbackProbability> -sink=<SinkPath> -rateLimit=<RateLimit> -rateBurst=<RateBurst> -rateScope=<RateScope> -rateClientHeader=<RateClientHeader> -rateDrop=<RateDrop> -defaultRes=<DefaultResponseFile> -proxy=<ProxyURL> -maxBody=<MaxBodySize> -snapshot=<SnapshotFile>")
+		fmt.Println()
+		fmt.Println("config:  Json configuration file with default options and named profiles, as local, ci or perf. By default none")
+		fmt.Println("profile: Profile at the configuration file to apply over its default options. By default none")
+		fmt.Println()
+		fmt.Println("Every option can be set as well through an environment variable, as " + ConfigEnvPrefix + "PORT, or at the configuration file.")
+		fmt.Println("Flags take precedence over environment variables and those over the configuration file.")
+		fmt.Println("Its \"" + EndpointsMember + "\" member hosts several named endpoints, selected by \"" + PrefixOption + "\" at DOCUMENT_URI or the request path.")
+		fmt.Println()
+		fmt.Println("host:  Host name for this FastCGI process.   By default " + options.Host)
+		fmt.Println("port:  Port number for this FastCGI process. By default " + options.Port)
+		fmt.Println("location: NGINX location of this FastCGI process, probes answered at <location>/" + jsonmock.HealthSegment + " and <location>/" + jsonmock.ReadySegment + ". By default " + config.Location)
+		fmt.Println()
+		fmt.Println("map: Fake mapped request/response file. By default " + config.MapFile)
+		fmt.Println("req: Json Schema to validate requests.  By default " + config.RequestSchemaFile)
+		fmt.Println("res: Json Schema to validate responses. By default " + config.ResponseSchemaFile)
+		fmt.Println("schemas: Folder of Json Schemas preloaded to resolve $ref among files. By default the folder of req")
+		fmt.Println()
+		fmt.Printf("strict: Refuse to start with any invalid or duplicated entry at map. By default %t\n", config.Strict)
+		fmt.Println("report: Json validation report of every entry at map, '-' for standard output. By default none")
+		fmt.Println()
+		fmt.Printf("debug:  Flag to force debug mode. By default %t\n", config.ForcedDebug)
+		fmt.Println("debugParameter: Query parameter that turns on debug mode for a single request. By default " + config.DebugParameter)
+		fmt.Println("log:    File to append logs to. By default standard error")
+		fmt.Printf("drain:  Maximum time to serve in-flight requests on SIGINT/SIGTERM. By default %v\n", options.DrainTimeout)
+		fmt.Println("admin:  Address of a plain HTTP listener with a web dashboard of mappings, recent requests and validation errors, where debug mode can be forced at runtime. By default none")
+		fmt.Println()
+		fmt.Println("generate: Fake responses from the response Json Schema: off, miss (when no entry matches) or always. By default " + config.Generate)
+		fmt.Printf("seed:     Seed to make those fake responses deterministic. By default %d\n", config.Seed)
+		fmt.Println("bidder:   Configuration file of a synthetic OpenRTB bidder answering unmatched bid requests. By default none")
 		fmt.Println()
-		fmt.Println("Usage: " + os.Args[0] + " -host=<host> -port=<port> -map=<MockRequestResponseFile> -req=<RequestJsonSchema> -res=<ResponseJsonSchema> -debug=<ForcedDebug>")
+		fmt.Println("callbacks:           Notices to fire after serving a bid response, with ${AUCTION_PRICE}, ${AUCTION_ID}, ... expanded: nurl, burl or nurl,burl. By default none")
+		fmt.Printf("callbackDelay:       Delay before firing every notice. By default %v\n", config.CallbackDelay)
+		fmt.Printf("callbackProbability: Probability of firing every notice, between 0 and 1. By default %v\n", *config.CallbackProbability)
+		fmt.Println("sink:                Path right after the location recording every notice received, as /testingEnd/notifications/burl?price=1.23, queried at <sink>/" + jsonmock.SinkHitsSegment + "/<kind>?<param>=<value>&" + jsonmock.SinkCountParam + "=<count>. By default none")
 		fmt.Println()
-		fmt.Println("host:  Host name for this FastCGI process.   By default " + hostArg)
-		fmt.Println("port:  Port number for this FastCGI process. By default " + portArg)
+		fmt.Println("rateLimit:        Requests per second before answering 429 with Retry-After. By default none")
+		fmt.Println("rateBurst:        Requests allowed at once over that rate. By default the rate itself")
+		fmt.Println("rateScope:        Token bucket shared by every request, one per map entry or one per client: " + jsonmock.RateScopeGlobal + ", " + jsonmock.RateScopeEntry + " or " + jsonmock.RateScopeClient + ". By default " + config.RateScope)
+		fmt.Println("rateClientHeader: Header telling clients apart instead of their remote address. By default none")
+		fmt.Printf("rateDrop:         Drop requests over the limit without any answer instead of 429. By default %t\n", config.RateDrop)
 		fmt.Println()
-		fmt.Println("map: Fake mapped request/response file. By default " + mockRequestResponseFile)
-		fmt.Println("req: Json Schema to validate requests.  By default " + requestJsonSchemaFile)
-		fmt.Println("res: Json Schema to validate responses. By default " + responseJsonSchemaFile)
+		fmt.Println("defaultRes: Json response answered when nothing else matched, valid against res. By default none")
+		fmt.Println("proxy:      Real server answering whatever nothing else matched, instead of a default response. By default none")
+		fmt.Printf("maxBody:    Largest request body in bytes, answered with 413 otherwise. By default %d\n", config.MaxBodySize)
+		fmt.Println("snapshot:   Mock state loaded at startup instead of map when it exists, and written back at shutdown. By default none")
 		fmt.Println()
-		fmt.Printf("debug:  Flag to force debug mode. By default %b\n", forcedDebug)
+		fmt.Println("Just to check out the map without serving it: " + os.Args[0] + " " + ValidateCommand + " -help")
+		fmt.Println("To build maps and Json Schemas out of an OpenAPI document or a HAR capture: " + os.Args[0] + " " + ImportCommand + " -help")
+		fmt.Println("To replay the map with curl, other tools or Go tests: " + os.Args[0] + " " + ExportCommand + " -help")
 		fmt.Println()
 		fmt.Println("Being a FastCGI, don't forget to properly configure NGINX. For example, something similar to:")
 		fmt.Println()
@@ -178,464 +295,84 @@ func cmdLine() (string, string, string, string, string, bool) {
 		fmt.Println("    fastcgi_param SCHEME              $scheme; ")
 		fmt.Println(" } ")
 		fmt.Println("")
-
-		os.Exit(0)
-	}
-
-	flag.StringVar(&hostArg, "host", hostArg, "Host name for this FastCGI process.")
-	flag.StringVar(&portArg, "port", portArg, "Port name for this FastCGI process.")
-	flag.StringVar(&mockRequestResponseFile, "map", mockRequestResponseFile, "Fake mapped request/response file.")
-	flag.StringVar(&requestJsonSchemaFile, "req", requestJsonSchemaFile, "Json Schema to validate requests.")
-	flag.StringVar(&responseJsonSchemaFile, "res", responseJsonSchemaFile, "Json Schema to validate responses.")
-	flag.BoolVar(&forcedDebug, "debug", forcedDebug, "Flag to force debug mode.")
-	flag.Parse()
-
-	return hostArg, portArg, mockRequestResponseFile, requestJsonSchemaFile, responseJsonSchemaFile, forcedDebug
-}
-
-// validate fake request response map against their json schemas
-func validateMockRequestResponseFile(mockRequestResponseFile string, requestJsonSchemaFile string, responseJsonSchemaFile string, debug bool) (RequestResponseMap, gojsonschema.JSONLoader, error) {
-
-	// regexpr to detect 'debug' params
-	var debugRegexp = regexp.MustCompile("^" + DebugParameter + "")
-	var err error
-	var reqresmap RequestResponseMap = make(map[string]QueryResponse)
-	var reqJsonSchema gojsonschema.JSONLoader
-
-	mock, err := validateMockInput(mockRequestResponseFile)
-	if err != nil {
-		return reqresmap, reqJsonSchema, err
-	}
-
-	req, err := ioutil.ReadFile(requestJsonSchemaFile)
-	if err != nil {
-		log.Fatal(err)
-		return reqresmap, reqJsonSchema, errors.New("Unable to read Request Json Schema File.")
-	}
-
-	res, err := ioutil.ReadFile(responseJsonSchemaFile)
-	if err != nil {
-		log.Fatal(err)
-		return reqresmap, reqJsonSchema, errors.New("Unable to read Response Json Schema File.")
 	}
 
-	reqJsonSchema = gojsonschema.NewStringLoader(string(req))
-	resJsonSchema := gojsonschema.NewStringLoader(string(res))
-
-	type ReqRes struct {
-		Qry      string           `json:"query,omitempty"`
-		Req      *json.RawMessage `json:"req,omitempty"`
-		Res      *json.RawMessage `json:"res"`
-		request  string
-		response string
-	}
-	dec := json.NewDecoder(strings.NewReader(string(mock)))
-
-	err = ignoreFirstBracket(dec)
+	flags.StringVar(&options.Host, "host", options.Host, "Host name for this FastCGI process.")
+	flags.StringVar(&options.Port, "port", options.Port, "Port name for this FastCGI process.")
+	flags.StringVar(&config.Location, "location", config.Location, "NGINX location of this FastCGI process, in front of its probes; every endpoint at its prefix.")
+	configFlags(flags, config)
+	flags.StringVar(&options.ReportFile, "report", options.ReportFile, "Json validation report of every entry at map, '-' for standard output.")
+	flags.StringVar(&options.LogFile, "log", options.LogFile, "File to append logs to.")
+	flags.DurationVar(&options.DrainTimeout, "drain", options.DrainTimeout, "Maximum time to serve in-flight requests on SIGINT/SIGTERM.")
+	flags.StringVar(&options.Admin, "admin", options.Admin, "Address of a plain HTTP listener with a web dashboard.")
+	endpoints, err := parseOptions(flags, os.Args[1:], true)
 	if err != nil {
-		return reqresmap, reqJsonSchema, err
+		fmt.Println(err)
+		os.Exit(2)
 	}
 
-	// read object {"req": string, "res": string}
-	for dec.More() {
-		var rr ReqRes
-		err = dec.Decode(&rr)
-		if err != nil {
-			log.Fatal(err)
-			return reqresmap, reqJsonSchema, errors.New("Unable to process object at Mock Request Response File")
-		}
-
-		rr.request, err = toString(rr.Req)
-		if err != nil {
-			log.Println("Unable to process request object at Mock Request Response File")
-			continue
-		}
-
-		rr.response, err = toString(rr.Res)
-		if err != nil {
-			log.Println("Unable to process response object at Mock Request Response File")
-			continue
-		}
-
-		rr.Qry = orderQueryByParams(rr.Qry, debugRegexp)
-		if debug {
-			if len(rr.Qry) > 0 {
-				log.Printf("%v %v -> %v\n", rr.Qry, rr.request, rr.response)
-			} else {
-				log.Printf(" %v -> %v\n", rr.request, rr.response)
-			}
-		}
-
-		// request could be empty because it's an optative field
-		if len(rr.request) > 0 {
-			if !validateRequest(reqJsonSchema, rr.request) {
-				continue
-			}
-		}
-
-		if !validateResponse(resJsonSchema, rr.response) {
-			continue
-		}
-
-		var key string
-
-		// request could be empty because it's an optative field
-		if len(rr.request) > 0 {
-
-			// add pair to the map but after compacting those json
-			key, err := compactJson([]byte(rr.request))
-			if err != nil {
-				log.Println("This request will be ignored")
-				continue
-			}
-			if len(rr.Qry) > 0 {
-				// key must take into account as well the provided query
-				key = "[" + rr.Qry + "]" + key
-			}
-
-		} else { // request empty
-
-			if len(rr.Qry) > 0 {
-				// key must take only the provided query
-				key = "[" + rr.Qry + "]"
-			}
-
-		}
-
-		response, err := compactJson([]byte(rr.response))
-		if err != nil {
-			log.Println("That response will be ignored")
-			continue
-		}
-		var value QueryResponse
-		value.response = response
-		reqresmap[key] = value
-	}
-
-	err = ignoreLastBracket(dec)
-	if err != nil {
-		return reqresmap, reqJsonSchema, err
+	// -h, -help and --help are already taken into account by flags
+	if flags.Arg(0) == "help" || flags.Arg(0) == "/?" {
+		flags.Usage()
+		os.Exit(0)
 	}
 
-	// return result
-	if len(reqresmap) == 0 {
-		err = errors.New("Unable to validate any entry at Mock Request Response File")
+	// every endpoint inherits the options of the server, its schema folder being the one of its own req
+	names := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		names = append(names, name)
 	}
-	return reqresmap, reqJsonSchema, err
-}
-
-// convert into an string
-func toString(raw *json.RawMessage) (string, error) {
-	if raw != nil {
-		noSoRaw, err := json.Marshal(raw)
+	sort.Strings(names)
+	for _, name := range names {
+		endpoint, err := endpointOptions(name, *config, endpoints[name])
 		if err != nil {
-			log.Fatal(err)
-			return "", err
-		}
-		return string(noSoRaw), nil
-	} else {
-		return "", nil
-	}
-}
-
-// order query string by params in order to match ordered generated r.URL.Query() values later on
-func orderQueryByParams(query string, debugRegexp *regexp.Regexp) string {
-	if len(query) > 0 {
-		list := strings.Split(query, "&")
-		sort.Strings(list) // supposed short lists than don't care to be ordered in memory
-		var result string
-		// remove debug parameters
-		for i, v := range list {
-			v = debugRegexp.ReplaceAllString(v, "")
-			if len(v) == 0 {
-				continue
-			} else if len(v) > 0 && v[0] == '=' {
-				continue
-			} else {
-				if len(result) > 0 {
-					result += "&"
-				}
-				result += list[i]
-			}
-		}
-		return result
-	}
-	return ""
-}
-
-// compact json to make it easy to look into the map for equivalent keys
-func compactJson(loose []byte) (string, error) {
-
-	compactedBuffer := new(bytes.Buffer)
-	err := json.Compact(compactedBuffer, loose)
-	if err != nil {
-		log.Fatal(err)
-		return "", err
-	}
-	return compactedBuffer.String(), nil
-}
-
-// validation request
-func validateRequest(reqJsonSchema gojsonschema.JSONLoader, rrReq string) bool {
-
-	result, err := gojsonschema.Validate(reqJsonSchema, gojsonschema.NewStringLoader(rrReq))
-	if err != nil {
-		log.Fatal(err)
-		log.Println("This request will be ignored")
-		return false
-	}
-	if !result.Valid() {
-		log.Println("Request is not valid. See errors: ")
-		for _, desc := range result.Errors() {
-			log.Printf("- %s\n", desc)
+			fmt.Println(err)
+			os.Exit(2)
 		}
-		log.Println("That request will be ignored")
-		return false
+		options.Endpoints = append(options.Endpoints, endpoint)
 	}
-	return true
-}
-
-// validation response
-func validateResponse(resJsonSchema gojsonschema.JSONLoader, rrRes string) bool {
 
-	result, err := gojsonschema.Validate(resJsonSchema, gojsonschema.NewStringLoader(rrRes))
-	if err != nil {
-		log.Fatal(err)
-		log.Println("This response will be ignored")
-		return false
-	}
-	if !result.Valid() {
-		log.Println("Response is not valid. See errors: ")
-		for _, desc := range result.Errors() {
-			log.Printf("- %s\n", desc)
-		}
-		log.Println("That response will be ignored")
-		return false
+	if len(config.SchemaDir) == 0 {
+		config.SchemaDir = filepath.Dir(config.RequestSchemaFile)
 	}
 
-	return true
+	return options
 }
 
-// ignore first bracket when json mock Request Response file is decoded
-func ignoreFirstBracket(dec *json.Decoder) error {
-	_, err := dec.Token()
-	if err != nil {
-		log.Fatal(err)
-		return errors.New("Unable to process first token at Mock Request Response File")
-	}
-	return nil
-}
-
-// ignore last bracket when json mock Request Response file is decoded
-func ignoreLastBracket(dec *json.Decoder) error {
-	_, err := dec.Token()
-	if err != nil {
-		log.Fatal(err)
-		return errors.New("Unable to process last token at Mock Request Response File")
+// options of the jsonmock package, the same for the server and every endpoint
+func configFlags(flags *flag.FlagSet, config *jsonmock.Config) {
+	flags.StringVar(&config.MapFile, "map", config.MapFile, "Fake mapped request/response file.")
+	flags.StringVar(&config.RequestSchemaFile, "req", config.RequestSchemaFile, "Json Schema to validate requests.")
+	flags.StringVar(&config.ResponseSchemaFile, "res", config.ResponseSchemaFile, "Json Schema to validate responses.")
+	flags.StringVar(&config.SchemaDir, "schemas", config.SchemaDir, "Folder of Json Schemas preloaded to resolve $ref among files.")
+	flags.BoolVar(&config.Strict, "strict", config.Strict, "Refuse to start with any invalid or duplicated entry at map.")
+	flags.BoolVar(&config.ForcedDebug, "debug", config.ForcedDebug, "Flag to force debug mode.")
+	flags.StringVar(&config.DebugParameter, "debugParameter", config.DebugParameter, "Query parameter that turns on debug mode for a single request.")
+	flags.StringVar(&config.Generate, "generate", config.Generate, "Fake responses from the response Json Schema: off, miss or always.")
+	flags.Int64Var(&config.Seed, "seed", config.Seed, "Seed to make those fake responses deterministic.")
+	flags.StringVar(&config.BidderConfigFile, "bidder", config.BidderConfigFile, "Configuration file of a synthetic OpenRTB bidder answering unmatched bid requests.")
+	flags.StringVar(&config.Callbacks, "callbacks", config.Callbacks, "Notices to fire after serving a bid response: nurl, burl or nurl,burl.")
+	flags.DurationVar(&config.CallbackDelay, "callbackDelay", config.CallbackDelay, "Delay before firing every notice.")
+	// storage of its own, not shared with the server options an endpoint starts from
+	probability := 1.0
+	if config.CallbackProbability != nil {
+		probability = *config.CallbackProbability
 	}
-	return nil
+	config.CallbackProbability = &probability
+	flags.Float64Var(config.CallbackProbability, "callbackProbability", probability, "Probability of firing every notice, between 0 and 1.")
+	flags.StringVar(&config.SinkPath, "sink", config.SinkPath, "Path right after the location recording every notice received, queried at <sink>/"+jsonmock.SinkHitsSegment+".")
+	flags.Float64Var(&config.RateLimit, "rateLimit", config.RateLimit, "Requests per second before answering 429 with Retry-After.")
+	flags.IntVar(&config.RateBurst, "rateBurst", config.RateBurst, "Requests allowed at once over that rate.")
+	flags.StringVar(&config.RateScope, "rateScope", config.RateScope, "Token bucket shared by every request, one per map entry or one per client: global, entry or client.")
+	flags.StringVar(&config.RateClientHeader, "rateClientHeader", config.RateClientHeader, "Header telling clients apart instead of their remote address.")
+	flags.BoolVar(&config.RateDrop, "rateDrop", config.RateDrop, "Drop requests over the limit without any answer instead of 429.")
+	flags.StringVar(&config.DefaultResponseFile, "defaultRes", config.DefaultResponseFile, "Json response answered when nothing else matched, valid against res.")
+	flags.StringVar(&config.ProxyURL, "proxy", config.ProxyURL, "Real server answering whatever nothing else matched, instead of a default response.")
+	flags.Int64Var(&config.MaxBodySize, "maxBody", config.MaxBodySize, "Largest request body in bytes, answered with 413 otherwise.")
+	flags.StringVar(&config.SnapshotFile, "snapshot", config.SnapshotFile, "Mock state loaded at startup instead of map when it exists and map was not edited after it, and written back at shutdown.")
 }
 
-// validate just mock input
-func validateMockInput(mockRequestResponseFile string) ([]byte, error) {
-
-	mock, err := ioutil.ReadFile(mockRequestResponseFile)
-	if err != nil {
-		log.Fatal(err)
-		return mock, errors.New("Unable to read Mock Request Response File.")
-	}
-
-	// validate the own mock input
-	mockJsonSchema := gojsonschema.NewStringLoader(`{ 
-		"$schema": "http://json-schema.org/draft-04/schema#",
-  		"title": "Mock Request Response Json Schema",
-  		"description": "version 0.0.1",
-    	"type": "array",
-    	"items": {
-    		"type": "object",
-    		"properties": {
-      			"req": {
-        			"type": "object"
-      			},
-      			"res": {
-        			"type": "object"
-      		   },
-               "query": {
-                    "type": "string"
-               }
-             },
-    		"required": [
-      			"res"
-    		]
-  		}
-	}`)
-
-	result, err := gojsonschema.Validate(mockJsonSchema, gojsonschema.NewStringLoader(string(mock)))
-	if err != nil {
-		log.Fatal(err)
-		return mock, errors.New("Unable to process mock Json Schema")
-	}
-
-	if !result.Valid() {
-		log.Println("Mock Request Response File is not valid. See errors: ")
-		for _, desc := range result.Errors() {
-			log.Printf("- %s\n", desc)
-		}
-		return mock, errors.New("Invalid Mock Request Response File")
-	}
-
-	// success
-	return mock, nil
-}
-
-// must have at least ServeHTTP(), otherwise you will get this error
-// *customHandler does not implement http.Handler (missing ServeHTTP method)
-func (c *customHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-
-	debug := (r.URL.Query()[DebugParameter] != nil) || c.forcedDebug
-	var debugRegexp = regexp.MustCompile("^" + DebugParameter + "")
-
-	if r.Method == http.MethodHead {
-		if debug {
-			log.Println("Requested Method HEAD. Probably a kind of ping")
-		}
-		http.NoBody.WriteTo(w)
-		r.Body.Close()
-		return
-	}
-
-	// GET params as a string
-	query := QueryAsString(r)
-	if debug {
-		log.Println(query)
-	}
-
-	if r.ContentLength > 0 {
-
-		// get body request to process
-		body, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
-			if debug {
-				log.Println(err)
-			}
-		}
-
-		if debug {
-			log.Println("Body received: " + string(body))
-		}
-
-		// avoid processing before having booted up completely
-		if c.rrmap != nil && len(*c.rrmap) > 0 {
-
-			// really not needed, no invalid request in our map, but it's good to provide some feedback to our logs
-			if validateRequest(*c.reqJS, string(body)) {
-
-				key, err := compactJson(body)
-				if err != nil {
-					if debug {
-						log.Print(err)
-					}
-				}
-				if len(query) > 0 {
-					key = "[" + orderQueryByParams(query, debugRegexp) + "]" + key
-				}
-				value := (*c.rrmap)[key]
-				if len(value.response) > 0 {
-					w.Header().Set("Content-Lenghth", strconv.Itoa(len(value.response)))
-					w.Header().Set("Content-Type", "application/json")
-					if _, err := w.Write([]byte(value.response)); err != nil {
-						http.Error(w, err.Error(), http.StatusUnprocessableEntity)
-						if debug {
-							log.Println(err)
-						}
-					}
-					if debug {
-						log.Println("Sent back: " + value.response)
-					}
-				} else {
-					http.Error(w, "key not found at internal cache", http.StatusNoContent)
-					if debug {
-						log.Println("key not found at internal cache")
-					}
-				}
-
-			} else {
-				http.Error(w, "Body Json Request doesn't comply with its expected Json Schema", http.StatusUnprocessableEntity)
-			}
-		}
-
-	} else {
-		if debug {
-			log.Println("empty request body received")
-		}
-
-		if len(query) > 0 {
-			key := "[" + orderQueryByParams(query, debugRegexp) + "]"
-			value := (*c.rrmap)[key]
-			if len(value.response) > 0 {
-				w.Header().Set("Content-Lenghth", strconv.Itoa(len(value.response)))
-				w.Header().Set("Content-Type", "application/json")
-				if _, err := w.Write([]byte(value.response)); err != nil {
-					http.Error(w, err.Error(), http.StatusUnprocessableEntity)
-					if debug {
-						log.Println(err)
-					}
-				}
-				if debug {
-					log.Println("Sent back: " + value.response)
-				}
-			} else {
-				http.Error(w, "key not found at internal cache", http.StatusNoContent)
-				if debug {
-					log.Println("key not found at internal cache")
-				}
-			}
-		} else {
-			http.Error(w, "empty query with empty request body", http.StatusNoContent)
-			if debug {
-				log.Println("empty query with empty request body")
-			}
-		}
-
-	}
-
-	if debug {
-		log.Printf("Processed request of %d bytes", r.ContentLength)
-	}
-}
-
-// convert query parameter into a string to be used as index in the map
-func QueryAsString(r *http.Request) string {
-
-	// try to get IN ORDER all the parameters
-	keys := []string{}
-	for k, _ := range r.URL.Query() {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-
-	query := ""
-	for _, k := range keys {
-		if k == DebugParameter {
-			continue
-		}
-		if len(query) > 0 {
-			query += "&"
-		}
-		v := r.URL.Query()[k]
-		if len(v) > 0 { // there might be repeated params
-			query += k + "="
-			for i, w := range v {
-				if i > 0 {
-					query += ","
-				}
-				query += w
-			}
-		} else {
-			// is a Flag
-			query += k
-		}
-	}
-	return query
+// file at the data folder next to the binary
+func defaultDataFile(name string) string {
+	return filepath.Dir(os.Args[0]) + filepath.FromSlash("/") + jsonmock.DefaultDataDir + filepath.FromSlash("/") + name
 }
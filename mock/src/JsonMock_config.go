@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xue2sheng/postJsonTest/mock/src/jsonmock"
+)
+
+// Every option can be set through an environment variable with this prefix, as JSONMOCK_PORT
+const ConfigEnvPrefix = "JSONMOCK_"
+
+// Options to choose a configuration file and one of its profiles
+const (
+	ConfigOption  = "config"
+	ProfileOption = "profile"
+)
+
+// Members of the configuration file with its named profiles and endpoints
+const (
+	ProfilesMember  = "profiles"
+	EndpointsMember = "endpoints"
+)
+
+// Endpoint option with its path prefix
+const PrefixOption = "prefix"
+
+// options taken as relative to the folder of the configuration file
+var pathOptions = map[string]bool{"map": true, "req": true, "res": true, "schemas": true, "report": true, "bidder": true, "defaultRes": true, "snapshot": true, "log": true}
+
+// parse arguments and complete them with environment variables and the configuration file: flags > env > file
+// options unknown to flags are refused at the configuration file only when strictNames; returns its endpoints by name
+func parseOptions(flags *flag.FlagSet, args []string, strictNames bool) (map[string]map[string]string, error) {
+
+	configFile := flags.String(ConfigOption, "", "Json configuration file with default options and named profiles.")
+	profile := flags.String(ProfileOption, "", "Profile at the configuration file to apply over its default options.")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	// environment variables, even the ones choosing the configuration file and profile
+	var err error
+	flags.VisitAll(func(f *flag.Flag) {
+		name := ConfigEnvPrefix + strings.ToUpper(f.Name)
+		value, found := os.LookupEnv(name)
+		if !found || set[f.Name] || err != nil {
+			return
+		}
+		if err = flags.Set(f.Name, value); err != nil {
+			err = errors.New(name + ": " + err.Error())
+			return
+		}
+		set[f.Name] = true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(*configFile) == 0 {
+		if len(*profile) > 0 {
+			return nil, errors.New("Profile " + *profile + " without any configuration file")
+		}
+		return nil, nil
+	}
+	options, endpoints, err := readConfigFile(*configFile, *profile)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if flags.Lookup(name) == nil {
+			if strictNames {
+				return nil, errors.New(*configFile + ": unknown option " + name)
+			}
+			continue
+		}
+		if set[name] {
+			continue
+		}
+		if err = flags.Set(name, options[name]); err != nil {
+			return nil, errors.New(*configFile + ": " + name + ": " + err.Error())
+		}
+	}
+	return endpoints, nil
+}
+
+// apply the options of an endpoint over a copy of the server ones; relative paths were already resolved
+func endpointOptions(name string, base jsonmock.Config, members map[string]string) (EndpointOptions, error) {
+
+	endpoint := EndpointOptions{Name: name, Prefix: "/" + name, Config: base}
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.StringVar(&endpoint.Prefix, PrefixOption, endpoint.Prefix, "Path prefix of the endpoint.")
+	flags.StringVar(&endpoint.ReportFile, "report", endpoint.ReportFile, "Json validation report of every entry at map.")
+	configFlags(flags, &endpoint.Config)
+
+	names := make([]string, 0, len(members))
+	for option := range members {
+		names = append(names, option)
+	}
+	sort.Strings(names)
+	for _, option := range names {
+		if flags.Lookup(option) == nil {
+			return endpoint, errors.New("Endpoint " + name + ": unknown option " + option)
+		}
+		if err := flags.Set(option, members[option]); err != nil {
+			return endpoint, errors.New("Endpoint " + name + ": " + option + ": " + err.Error())
+		}
+	}
+
+	// every endpoint its own state, next to the snapshot of the server unless told otherwise
+	if _, found := members["snapshot"]; !found && len(base.SnapshotFile) > 0 {
+		ext := filepath.Ext(base.SnapshotFile)
+		endpoint.Config.SnapshotFile = strings.TrimSuffix(base.SnapshotFile, ext) + "." + name + ext
+	}
+	return endpoint, nil
+}
+
+// options at the configuration file, the ones of that profile over the default ones, and its endpoints
+func readConfigFile(configFile string, profile string) (map[string]string, map[string]map[string]string, error) {
+
+	content, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	var members map[string]json.RawMessage
+	if err = json.Unmarshal(content, &members); err != nil {
+		return nil, nil, errors.New(configFile + ": " + err.Error())
+	}
+
+	profiles := make(map[string]map[string]json.RawMessage)
+	if raw, found := members[ProfilesMember]; found {
+		if err = json.Unmarshal(raw, &profiles); err != nil {
+			return nil, nil, errors.New(configFile + ": " + ProfilesMember + ": " + err.Error())
+		}
+		delete(members, ProfilesMember)
+	}
+
+	endpointMembers := make(map[string]map[string]json.RawMessage)
+	if raw, found := members[EndpointsMember]; found {
+		if err = json.Unmarshal(raw, &endpointMembers); err != nil {
+			return nil, nil, errors.New(configFile + ": " + EndpointsMember + ": " + err.Error())
+		}
+		delete(members, EndpointsMember)
+	}
+	endpoints := make(map[string]map[string]string)
+	for name, members := range endpointMembers {
+		endpoints[name] = make(map[string]string)
+		if err = addOptions(endpoints[name], members, filepath.Dir(configFile)); err != nil {
+			return nil, nil, errors.New(configFile + ": " + name + ": " + err.Error())
+		}
+	}
+
+	options := make(map[string]string)
+	if err = addOptions(options, members, filepath.Dir(configFile)); err != nil {
+		return nil, nil, errors.New(configFile + ": " + err.Error())
+	}
+	if len(profile) > 0 {
+		profileMembers, found := profiles[profile]
+		if !found {
+			available := []string{}
+			for name := range profiles {
+				available = append(available, name)
+			}
+			sort.Strings(available)
+			return nil, nil, errors.New(configFile + ": unknown profile " + profile + ", available ones: " + strings.Join(available, ", "))
+		}
+		if err = addOptions(options, profileMembers, filepath.Dir(configFile)); err != nil {
+			return nil, nil, errors.New(configFile + ": " + profile + ": " + err.Error())
+		}
+	}
+	return options, endpoints, nil
+}
+
+// json members as flag values; relative paths from the folder of the configuration file
+func addOptions(options map[string]string, members map[string]json.RawMessage, baseDir string) error {
+
+	for name, raw := range members {
+		var value string
+		if json.Unmarshal(raw, &value) != nil {
+			// numbers and booleans just as they are written
+			var scalar interface{}
+			if err := json.Unmarshal(raw, &scalar); err != nil {
+				return err
+			}
+			switch scalar.(type) {
+			case float64, bool:
+				value = string(raw)
+			default:
+				return errors.New(name + ": neither a string, a number nor a boolean")
+			}
+		} else if pathOptions[name] && len(value) > 0 && value != "-" && !filepath.IsAbs(value) {
+			value = filepath.Join(baseDir, filepath.FromSlash(value))
+		}
+		options[name] = value
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xue2sheng/postJsonTest/mock/src/jsonmock"
+)
+
+// compiled along with the main sources, as go test JsonMock_config_test.go JsonMock.go JsonMock_*.go
+
+const testConfigFile = `{
+	"port": "9001",
+	"debugParameter": "fromFile",
+	"generate": "miss",
+	"map": "maps/map.json",
+	"report": "/var/report.json",
+	"profiles": {
+		"ci": { "port": "9002", "strict": true, "snapshot": "state/ci.json" },
+		"other": { "seed": 7 }
+	},
+	"endpoints": {
+		"bid": { "prefix": "/bid", "map": "bid/map.json" }
+	}
+}`
+
+// flags as the ones of the command line, on a set of their own
+func testFlags(options *Options, config *jsonmock.Config) *flag.FlagSet {
+
+	flags := flag.NewFlagSet("JsonMock", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	flags.StringVar(&options.Port, "port", options.Port, "Port name for this FastCGI process.")
+	configFlags(flags, config)
+	flags.StringVar(&options.ReportFile, "report", options.ReportFile, "Json validation report of every entry at map.")
+	return flags
+}
+
+// set an environment variable, returning how to restore it
+func testEnv(t *testing.T, name string, value string) func() {
+
+	previous, found := os.LookupEnv(name)
+	if err := os.Setenv(name, value); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		if found {
+			os.Setenv(name, previous)
+		} else {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "jsonmock_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "jsonmock.json")
+	if err = ioutil.WriteFile(configFile, []byte(testConfigFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("precedence", func(t *testing.T) {
+		// the same option at the file, the environment and the command line
+		defer testEnv(t, ConfigEnvPrefix+"DEBUGPARAMETER", "fromEnv")()
+		defer testEnv(t, ConfigEnvPrefix+"GENERATE", "always")()
+		var options Options
+		config := jsonmock.Config{DebugParameter: "fromDefault"}
+		flags := testFlags(&options, &config)
+		endpoints, err := parseOptions(flags, []string{"-config=" + configFile, "-debugParameter=fromFlag"}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.DebugParameter != "fromFlag" {
+			t.Errorf("flag over env and file: %q", config.DebugParameter)
+		}
+		if config.Generate != "always" {
+			t.Errorf("env over file: %q", config.Generate)
+		}
+		if options.Port != "9001" {
+			t.Errorf("file over defaults: %q", options.Port)
+		}
+		if config.Strict {
+			t.Error("profile applied without being chosen")
+		}
+		if endpoints["bid"]["prefix"] != "/bid" {
+			t.Errorf("endpoints: %v", endpoints)
+		}
+	})
+
+	t.Run("profile", func(t *testing.T) {
+		defer testEnv(t, ConfigEnvPrefix+"PROFILE", "ci")()
+		var options Options
+		var config jsonmock.Config
+		flags := testFlags(&options, &config)
+		if _, err := parseOptions(flags, []string{"-config=" + configFile}, true); err != nil {
+			t.Fatal(err)
+		}
+		if options.Port != "9002" || !config.Strict {
+			t.Errorf("profile over defaults: port %q, strict %v", options.Port, config.Strict)
+		}
+		if config.DebugParameter != "fromFile" {
+			t.Errorf("defaults kept under the profile: %q", config.DebugParameter)
+		}
+		if config.Seed != 0 {
+			t.Errorf("another profile applied: seed %v", config.Seed)
+		}
+
+		// the environment over the profile too
+		defer testEnv(t, ConfigEnvPrefix+"PORT", "9003")()
+		options = Options{}
+		config = jsonmock.Config{}
+		flags = testFlags(&options, &config)
+		if _, err := parseOptions(flags, []string{"-config=" + configFile}, true); err != nil {
+			t.Fatal(err)
+		}
+		if options.Port != "9003" {
+			t.Errorf("env over profile: %q", options.Port)
+		}
+	})
+
+	t.Run("paths", func(t *testing.T) {
+		var options Options
+		var config jsonmock.Config
+		flags := testFlags(&options, &config)
+		endpoints, err := parseOptions(flags, []string{"-config=" + configFile, "-profile=ci"}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]string{
+			"map":          filepath.Join(dir, "maps", "map.json"),
+			"snapshot":     filepath.Join(dir, "state", "ci.json"),
+			"report":       filepath.FromSlash("/var/report.json"),
+			"endpoint map": filepath.Join(dir, "bid", "map.json"),
+		}
+		got := map[string]string{
+			"map":          config.MapFile,
+			"snapshot":     config.SnapshotFile,
+			"report":       options.ReportFile,
+			"endpoint map": endpoints["bid"]["map"],
+		}
+		for name, path := range expected {
+			if got[name] != path {
+				t.Errorf("%v: expected %q, got %q", name, path, got[name])
+			}
+		}
+
+		// paths at the command line are left as they are
+		options = Options{}
+		config = jsonmock.Config{}
+		flags = testFlags(&options, &config)
+		if _, err = parseOptions(flags, []string{"-config=" + configFile, "-map=here.json"}, true); err != nil {
+			t.Fatal(err)
+		}
+		if config.MapFile != "here.json" {
+			t.Errorf("flag path resolved: %q", config.MapFile)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		cases := map[string][]string{
+			"unknown profile":        {"-config=" + configFile, "-profile=missing"},
+			"profile without config": {"-profile=ci"},
+			"missing config":         {"-config=" + filepath.Join(dir, "missing.json")},
+		}
+		for name, args := range cases {
+			var options Options
+			var config jsonmock.Config
+			if _, err := parseOptions(testFlags(&options, &config), args, true); err == nil {
+				t.Errorf("%v: no error", name)
+			}
+		}
+
+		// options the flags do not know, only refused when asked to
+		flags := flag.NewFlagSet("JsonMock", flag.ContinueOnError)
+		flags.SetOutput(ioutil.Discard)
+		flags.String("port", "", "")
+		if _, err := parseOptions(flags, []string{"-config=" + configFile}, true); err == nil {
+			t.Error("unknown options accepted")
+		}
+		flags = flag.NewFlagSet("JsonMock", flag.ContinueOnError)
+		port := flags.String("port", "", "")
+		if _, err := parseOptions(flags, []string{"-config=" + configFile}, false); err != nil || *port != "9001" {
+			t.Errorf("unknown options not skipped: %v, port %q", err, *port)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/xue2sheng/postJsonTest/mock/src/jsonmock"
+)
+
+// Subcommand to turn the map into requests other tools can replay
+const ExportCommand = "export"
+
+// Formats of the export subcommand
+const (
+	FormatCurl = "curl"
+	FormatHAR  = "har"
+	FormatGo   = "go"
+)
+
+// Base URL of exported requests by default, as the one of the testers
+const DefaultBaseURL = "http://0.0.0.0/testingEnd?"
+
+// export every valid entry of the map as curl commands, a HAR capture or a Go test; returns the exit code
+func exportCommand(args []string) int {
+
+	flags := flag.NewFlagSet(ExportCommand, flag.ExitOnError)
+	config := jsonmock.Config{
+		MapFile:            defaultDataFile(jsonmock.DefaultMapFile),
+		RequestSchemaFile:  defaultDataFile(jsonmock.DefaultRequestSchemaFile),
+		ResponseSchemaFile: defaultDataFile(jsonmock.DefaultResponseSchemaFile),
+	}
+	configFlags(flags, &config)
+	format := flags.String("format", FormatCurl, "Export format: '"+FormatCurl+"' shell script, '"+FormatHAR+"' capture or '"+FormatGo+"' table-driven test.")
+	baseURL := flags.String("baseURL", DefaultBaseURL, "Base URL of the mock, every query after '?' and endpoint prefixes after it.")
+	output := flags.String("output", "-", "File to write to, '-' for standard output.")
+	flags.Usage = func() {
+		fmt.Println()
+		fmt.Println("Usage: " + os.Args[0] + " " + ExportCommand + " -config=<ConfigFile> -profile=<Profile> -map=<MockRequestResponseFile> -req=<RequestJsonSchema> -res=<ResponseJsonSchema> -schemas=<SchemaDir> -format=<Format> -baseURL=<BaseURL> -output=<OutputFile>")
+		fmt.Println()
+		fmt.Println("Exports a request matching every valid entry at map, along with its expected response:")
+		fmt.Println("  " + FormatCurl + ": a shell script with a curl command per entry")
+		fmt.Println("  " + FormatHAR + ":  a HAR capture to be imported by other tools")
+		fmt.Println("  " + FormatGo + ":   a Go table-driven test replaying every entry against its -baseURL flag, as go test -c <OutputFile>")
+		fmt.Println("Header predicates only known by a regular expression cannot be exported, nor entries matching by reqSchema or default ones.")
+		fmt.Println("When the configuration file has endpoints, the entries of every one of them are exported under its prefix.")
+		fmt.Println()
+		flags.PrintDefaults()
+	}
+	endpoints, err := parseOptions(flags, args, false)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if *format != FormatCurl && *format != FormatHAR && *format != FormatGo {
+		fmt.Println("Unknown export format: " + *format)
+		flags.Usage()
+		return 2
+	}
+
+	targets, err := endpointTargets(config, endpoints)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	exchanges := []jsonmock.Exchange{}
+	for _, target := range targets {
+		targetExchanges, report, err := jsonmock.Exchanges(target.Config)
+		if err != nil || !report.Clean() {
+			// just what the mock would serve, but say so
+			fmt.Fprintf(os.Stderr, "%s: %d total, %d valid, %d invalid, %d duplicated\n", report.File, report.Total, report.Valid, report.Invalid, report.Duplicated)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, exchange := range targetExchanges {
+			exchange.Path = target.Prefix
+			exchanges = append(exchanges, exchange)
+		}
+	}
+
+	var content []byte
+	switch *format {
+	case FormatCurl:
+		content = jsonmock.ExportCurl(exchanges, *baseURL)
+	case FormatHAR:
+		content, err = jsonmock.ExportHAR(exchanges, *baseURL)
+	case FormatGo:
+		content, err = jsonmock.ExportGoTest(exchanges, *baseURL)
+	}
+	if err == nil {
+		if *output == "-" {
+			_, err = os.Stdout.Write(content)
+		} else {
+			err = ioutil.WriteFile(*output, content, 0644)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xue2sheng/postJsonTest/mock/src/jsonmock"
+)
+
+// Subcommand to build maps and Json Schemas out of other formats
+const ImportCommand = "import"
+
+// Configuration file written by the import subcommand, with an endpoint per imported prefix
+const ImportConfigFile = "jsonmock.json"
+
+// import an OpenAPI document or a HAR capture into an output folder ready to be served; returns the exit code
+func importCommand(args []string) int {
+
+	flags := flag.NewFlagSet(ImportCommand, flag.ExitOnError)
+	openAPIFile := flags.String("openapi", "", "OpenAPI 3 json document to import.")
+	harFile := flags.String("har", "", "HAR capture to import.")
+	outputDir := flags.String("output", ".", "Folder to write the configuration file, maps and Json Schemas to.")
+	urlPattern := flags.String("url", "", "Regular expression the url of every imported HAR entry must match.")
+	methods := flags.String("methods", "", "Comma separated http methods of the imported HAR entries, every one when empty.")
+	config := jsonmock.Config{
+		RequestSchemaFile:  defaultDataFile(jsonmock.DefaultRequestSchemaFile),
+		ResponseSchemaFile: defaultDataFile(jsonmock.DefaultResponseSchemaFile),
+	}
+	flags.StringVar(&config.RequestSchemaFile, "req", config.RequestSchemaFile, "Json Schema to validate HAR requests.")
+	flags.StringVar(&config.ResponseSchemaFile, "res", config.ResponseSchemaFile, "Json Schema to validate HAR responses.")
+	flags.StringVar(&config.SchemaDir, "schemas", config.SchemaDir, "Folder of Json Schemas preloaded to resolve $ref among files.")
+	reportFile := flags.String("report", "", "Json report of the dropped HAR entries, '-' for standard output.")
+	flags.Usage = func() {
+		fmt.Println()
+		fmt.Println("Usage: " + os.Args[0] + " " + ImportCommand + " -openapi=<OpenAPIFile> -output=<OutputDir>")
+		fmt.Println("       " + os.Args[0] + " " + ImportCommand + " -har=<HARFile> -url=<URLPattern> -methods=<Methods> -req=<RequestJsonSchema> -res=<ResponseJsonSchema> -schemas=<SchemaDir> -report=<ReportFile> -output=<OutputDir>")
+		fmt.Println()
+		fmt.Println("Writes, for every static path prefix of an OpenAPI 3 json document, as /users for /users/{id}:")
+		fmt.Println("  <prefix>/<operationId>.request.json and .response.json: Json Schemas of every operation")
+		fmt.Println("  <prefix>/" + jsonmock.DefaultRequestSchemaFile + " and " + jsonmock.DefaultResponseSchemaFile + ": any of those operations")
+		fmt.Println("  <prefix>/" + jsonmock.DefaultMapFile + ": stub entries out of their examples")
+		fmt.Println("and " + ImportConfigFile + " with an endpoint per prefix, to be served with -" + ConfigOption + "=<OutputDir>/" + ImportConfigFile)
+		fmt.Println()
+		fmt.Println("Converts the HAR entries with json bodies matching url and methods into <OutputDir>/" + jsonmock.DefaultMapFile + ",")
+		fmt.Println("deduplicated by lookup key and valid against req and res; every dropped entry is reported along with its reasons.")
+		fmt.Println()
+		fmt.Println("Exit code is not zero when any stub entry is invalid or duplicated, or when no HAR entry is imported.")
+		fmt.Println()
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+
+	switch {
+	case len(*openAPIFile) > 0 && len(*harFile) == 0:
+		return importOpenAPI(*openAPIFile, *outputDir)
+	case len(*harFile) > 0 && len(*openAPIFile) == 0:
+		if len(config.SchemaDir) == 0 {
+			config.SchemaDir = filepath.Dir(config.RequestSchemaFile)
+		}
+		return importHAR(*harFile, *urlPattern, strings.Split(*methods, ","), config, *reportFile, *outputDir)
+	}
+	flags.Usage()
+	return 2
+}
+
+// schemas, maps and configuration file of an OpenAPI document
+func importOpenAPI(openAPIFile string, outputDir string) int {
+
+	content, err := ioutil.ReadFile(openAPIFile)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	imported, err := jsonmock.ImportOpenAPI(content)
+	if err != nil {
+		fmt.Println(openAPIFile + ": " + err.Error())
+		return 2
+	}
+	for _, warning := range imported.Warnings {
+		fmt.Println("warning: " + warning)
+	}
+
+	code := 0
+	endpoints := make(map[string]map[string]string)
+	for _, endpoint := range imported.Endpoints {
+		dir := filepath.Join(outputDir, endpoint.Name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			fmt.Println(err)
+			return 2
+		}
+
+		entries := []jsonmock.Entry{}
+		files := map[string][]byte{
+			jsonmock.DefaultRequestSchemaFile:  endpoint.RequestSchema,
+			jsonmock.DefaultResponseSchemaFile: endpoint.ResponseSchema,
+		}
+		for _, operation := range endpoint.Operations {
+			if operation.RequestSchema != nil {
+				files[operation.Id+".request.json"] = operation.RequestSchema
+			}
+			if operation.ResponseSchema != nil {
+				files[operation.Id+".response.json"] = operation.ResponseSchema
+			}
+			entries = append(entries, operation.Entries...)
+		}
+		mapContent, err := marshalIndent(entries)
+		if err != nil {
+			fmt.Println(err)
+			return 2
+		}
+		files[jsonmock.DefaultMapFile] = mapContent
+		for name, content := range files {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), append(content, '\n'), 0644); err != nil {
+				fmt.Println(err)
+				return 2
+			}
+		}
+
+		// an endpoint without stubs could not start
+		if len(entries) == 0 {
+			fmt.Println("warning: endpoint " + endpoint.Name + " at " + endpoint.Prefix + " has no stub entry and is left out of " + ImportConfigFile)
+			continue
+		}
+		config := jsonmock.Config{
+			MapFile:            filepath.Join(dir, jsonmock.DefaultMapFile),
+			RequestSchemaFile:  filepath.Join(dir, jsonmock.DefaultRequestSchemaFile),
+			ResponseSchemaFile: filepath.Join(dir, jsonmock.DefaultResponseSchemaFile),
+			SchemaDir:          dir,
+		}
+		fmt.Println("endpoint " + endpoint.Name + " at " + endpoint.Prefix + ":")
+		report, err := jsonmock.Validate(config)
+		printSummary(report)
+		if err != nil {
+			fmt.Println(err)
+			code = 1
+		} else if !report.Clean() {
+			code = 1
+		}
+
+		// relative to the configuration file
+		endpoints[endpoint.Name] = map[string]string{
+			PrefixOption: endpoint.Prefix,
+			"map":        endpoint.Name + "/" + jsonmock.DefaultMapFile,
+			"req":        endpoint.Name + "/" + jsonmock.DefaultRequestSchemaFile,
+			"res":        endpoint.Name + "/" + jsonmock.DefaultResponseSchemaFile,
+			"schemas":    endpoint.Name,
+		}
+	}
+
+	configContent, err := marshalIndent(map[string]interface{}{EndpointsMember: endpoints})
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(outputDir, ImportConfigFile), append(configContent, '\n'), 0644)
+	}
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	return code
+}
+
+// map of the HAR entries that could be imported, reporting the dropped ones
+func importHAR(harFile string, urlPattern string, methods []string, config jsonmock.Config, reportFile string, outputDir string) int {
+
+	content, err := ioutil.ReadFile(harFile)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	imported, err := jsonmock.ImportHAR(content, urlPattern, methods, config)
+	if err != nil {
+		fmt.Println(harFile + ": " + err.Error())
+		return 2
+	}
+
+	if reportFile != "-" {
+		for _, dropped := range imported.Dropped {
+			fmt.Printf("dropped entry %d %s %s:\n", dropped.Index, dropped.Method, dropped.URL)
+			for _, reason := range dropped.Reasons {
+				fmt.Println("  - " + reason)
+			}
+		}
+	}
+	if len(reportFile) > 0 {
+		report, err := marshalIndent(imported.Dropped)
+		if err == nil {
+			report = append(report, '\n')
+			if reportFile == "-" {
+				_, err = os.Stdout.Write(report)
+			} else {
+				err = ioutil.WriteFile(reportFile, report, 0644)
+			}
+		}
+		if err != nil {
+			fmt.Println(err)
+			return 2
+		}
+	}
+
+	mapFile := filepath.Join(outputDir, jsonmock.DefaultMapFile)
+	mapContent, err := marshalIndent(imported.Entries)
+	if err == nil {
+		if err = os.MkdirAll(outputDir, 0755); err == nil {
+			err = ioutil.WriteFile(mapFile, append(mapContent, '\n'), 0644)
+		}
+	}
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if reportFile != "-" {
+		fmt.Printf("%s: %d imported, %d dropped\n", mapFile, len(imported.Entries), len(imported.Dropped))
+	}
+	if len(imported.Entries) == 0 {
+		return 1
+	}
+	return 0
+}
+
+// indented json as people write it, queries with '&' included
+func marshalIndent(value interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	enc := json.NewEncoder(&buffer)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
@@ -20,22 +20,20 @@ import (
 	"testing"
 
 	"github.com/xeipuuv/gojsonschema"
+	"github.com/xue2sheng/postJsonTest/mock/src/jsonmock"
 )
 
-// Already defined at JsonMock.go
-var DataDir = "data"
-var DebugParameter = "debug"
-
 // global const
 const MockDataFile string = "queries.json"
 
 // global due to lazyness
 var queryStr string
 var dataFile string
+var schemaDir string
 var checkUp bool
 var forcedDebug bool
 var goroutinesMax uint64
-var additionalSchema = make(map[string](*gojsonschema.JSONLoader))
+var additionalSchema = make(map[string](*gojsonschema.Schema))
 
 // read extra commandline arguments
 func init() {
@@ -43,12 +41,16 @@ func init() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
 	flag.StringVar(&queryStr, "queryStr", "http://0.0.0.0/testingEnd?", "Testing End address, including 'debug' parameter if needed")
-	mockDataFile := filepath.Dir(os.Args[0]) + filepath.FromSlash("/") + DataDir + filepath.FromSlash("/") + MockDataFile
+	mockDataFile := filepath.Dir(os.Args[0]) + filepath.FromSlash("/") + jsonmock.DefaultDataDir + filepath.FromSlash("/") + MockDataFile
 	flag.StringVar(&dataFile, "dataFile", mockDataFile, "Data File with Request/Response map. No validation will be carried out.")
-	flag.BoolVar(&checkUp, "checkUp", true, "Check it out that FastCGI is up and running through a HEAD request.")
+	flag.StringVar(&schemaDir, "schemaDir", "", "Folder of Json Schemas preloaded to resolve $ref among files. By default the folder of dataFile.")
+	flag.BoolVar(&checkUp, "checkUp", true, "Check it out that FastCGI is up and running, and its map ready, through its readyz endpoint.")
 	flag.Uint64Var(&goroutinesMax, "goroutinesMax", uint64(3*runtime.NumCPU()), "Maximum number of goroutines in parallel in order to avoid hoarding too much resources.")
 	flag.BoolVar(&forcedDebug, "debug", false, "Flag to force debug mode.")
 	flag.Parse()
+	if len(schemaDir) == 0 {
+		schemaDir = filepath.Dir(dataFile)
+	}
 	if len(queryStr) < 2 || strings.Index(queryStr, "?") != (len(queryStr)-1) || strings.LastIndex(queryStr, "/") == (len(queryStr)-2) {
 		fmt.Printf("Check it out that your -queryStr %v is the correct one expected by NGINX and ends in '?'\n", queryStr)
 		os.Exit(1)
@@ -64,7 +66,7 @@ func init() {
 type QuerySchema struct {
 	query   string
 	request string
-	schema  *gojsonschema.JSONLoader
+	schema  *gojsonschema.Schema
 }
 type Queries []QuerySchema
 
@@ -200,7 +202,7 @@ func ReadInfo(filename string, target string, t *testing.T) (Queries, error) {
 	queries := make(Queries, 0)
 
 	// regexpr to detect 'debug' params
-	var debugRegexp = regexp.MustCompile("^" + DebugParameter + "")
+	var debugRegexp = regexp.MustCompile("^" + jsonmock.DefaultDebugParameter + "")
 
 	// default schema is required
 	if info.DefaultSchema == nil {
@@ -216,9 +218,15 @@ func ReadInfo(filename string, target string, t *testing.T) (Queries, error) {
 		return nil, errors.New("Empty default Json Schema unable to validate responses.")
 	}
 
-	var defaultSchema gojsonschema.JSONLoader = gojsonschema.NewStringLoader(defaultSchemaStr)
-	if defaultSchema == nil {
-		return nil, errors.New("Invalid default Json Schema unable to validate responses.")
+	// every schema at the same folder can be referenced through relative $ref
+	store, err := jsonmock.NewSchemaStore(schemaDir)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultSchema, err := jsonmock.LoadInlineSchema(store, filename, "defaultSchema", defaultSchemaStr)
+	if err != nil {
+		return nil, errors.New("Invalid default Json Schema unable to validate responses: " + err.Error())
 	}
 	t.Logf("Read valid default schema at %s\n", dataFile)
 
@@ -238,13 +246,13 @@ func ReadInfo(filename string, target string, t *testing.T) (Queries, error) {
 					continue
 				}
 
-				var schema gojsonschema.JSONLoader = gojsonschema.NewStringLoader(schemaStr)
-				if defaultSchema == nil {
-					t.Logf("Unable to proccess additional an nil schema %s\n", info.AdditionalSchemas[i].Id)
+				schema, err := jsonmock.LoadInlineSchema(store, filename, info.AdditionalSchemas[i].Id, schemaStr)
+				if err != nil {
+					t.Logf("Unable to proccess additional schema %s: %s\n", info.AdditionalSchemas[i].Id, err.Error())
 					continue
 				}
 
-				additionalSchema[info.AdditionalSchemas[i].Id] = &schema
+				additionalSchema[info.AdditionalSchemas[i].Id] = schema
 			}
 		}
 	}
@@ -274,7 +282,7 @@ func ReadInfo(filename string, target string, t *testing.T) (Queries, error) {
 
 			// Decide what schema to use
 			if len(info.Items[i].Schema) == 0 || len(additionalSchema) == 0 {
-				queries = append(queries, QuerySchema{query, request, &defaultSchema})
+				queries = append(queries, QuerySchema{query, request, defaultSchema})
 			} else {
 				candidate := additionalSchema[info.Items[i].Schema]
 				if candidate != nil {
@@ -293,22 +301,33 @@ func TestRequests(t *testing.T) {
 	t.Log("-queryStr=" + queryStr)
 	// depends on your test configuration
 	t.Log("-dataFile=" + dataFile)
-	// depends if the server under test supports HEAD queries
+	// depends if the server under test supports readiness queries
 	t.Logf("-checkUp=%t\n", checkUp)
 	// depends on the test system resources
 	t.Logf("-goroutinesMax=%d\n", goroutinesMax)
 
 	// call that fastcgi to checkout whether it's up or not
 	if checkUp {
-		ping, err := http.Head(queryStr)
+		// readiness probe next to the testing end, as http://0.0.0.0/testingEnd/readyz, whatever its query
+		probe, err := url.Parse(queryStr)
+		if err != nil {
+			t.Error("Unable to parse queryStr. " + err.Error())
+			t.FailNow()
+		}
+		probe.Path = strings.TrimSuffix(probe.Path, "/") + "/" + jsonmock.ReadySegment
+		probe.RawQuery, probe.ForceQuery = "", false
+		ping, err := http.Get(probe.String())
 		if err != nil {
-			t.Error("Unable to request for HEAD info to the server. " + err.Error())
+			t.Error("Unable to request for readiness to the server. " + err.Error())
 			t.FailNow()
 		}
+		details, _ := ioutil.ReadAll(ping.Body)
+		ping.Body.Close()
 		if ping.StatusCode != http.StatusOK {
-			t.Error("Probably FastCGI down: " + ping.Status)
+			t.Error("Probably FastCGI down or its map not ready: " + ping.Status + " " + string(details))
 			t.FailNow()
 		}
+		t.Log("Ready: " + string(details))
 	}
 	// grab the real queries to launch
 	queries, err := ReadInfo(dataFile, queryStr, t)
@@ -359,7 +378,7 @@ func TestRequests(t *testing.T) {
 }
 
 // process specif request
-func checkQuery(current uint64, goroutinesRunning uint64, t *testing.T, queryPtr *string, requestPtr *string, schemaPtr *gojsonschema.JSONLoader, wg *sync.WaitGroup, failed *uint64, success *uint64) {
+func checkQuery(current uint64, goroutinesRunning uint64, t *testing.T, queryPtr *string, requestPtr *string, schemaPtr *gojsonschema.Schema, wg *sync.WaitGroup, failed *uint64, success *uint64) {
 
 	defer wg.Done()
 
@@ -418,7 +437,7 @@ func checkQuery(current uint64, goroutinesRunning uint64, t *testing.T, queryPtr
 		t.Logf("<%d:%d> Received: "+string(res)+"\n", current, goroutinesRunning)
 	}
 
-	result, err := gojsonschema.Validate(*schemaPtr, gojsonschema.NewStringLoader(string(res)))
+	result, err := schemaPtr.Validate(gojsonschema.NewStringLoader(string(res)))
 	if err != nil {
 		t.Errorf("<%d:%d> Failed Response validation for query "+*queryPtr+": "+err.Error()+"\n", current, goroutinesRunning)
 		atomic.AddUint64(failed, 1)
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"compress/gzip"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -20,11 +21,10 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
+
+	"github.com/xue2sheng/postJsonTest/mock/src/jsonmock"
 )
 
-// Already declade at JsonMock
-var DataDir = "data"
-var MockRequestResponseFile = "requestResponseMap.json"
 var queryStr string
 var dataFile string
 var checkUp bool
@@ -33,9 +33,12 @@ var goroutinesMax uint64
 
 // To process Json input file
 type ReqRes struct {
-	Qry string           `json:"query,omitempty"`
-	Req *json.RawMessage `json:"req"`
-	Res *json.RawMessage `json:"res"`
+	Qry        string           `json:"query,omitempty"`
+	Req        *json.RawMessage `json:"req"`
+	Res        *json.RawMessage `json:"res"`
+	Body       *string          `json:"body,omitempty"`
+	BodyFile   string           `json:"bodyFile,omitempty"`
+	BodyBase64 string           `json:"bodyBase64,omitempty"`
 }
 
 // read extra commandline arguments
@@ -44,9 +47,9 @@ func init() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
 	flag.StringVar(&queryStr, "queryStr", "http://0.0.0.0/testingEnd?", "Testing End address, including 'debug' parameter if needed")
-	mockRequestResponseFile := filepath.Dir(os.Args[0]) + filepath.FromSlash("/") + DataDir + filepath.FromSlash("/") + MockRequestResponseFile
+	mockRequestResponseFile := filepath.Dir(os.Args[0]) + filepath.FromSlash("/") + jsonmock.DefaultDataDir + filepath.FromSlash("/") + jsonmock.DefaultMapFile
 	flag.StringVar(&dataFile, "dataFile", mockRequestResponseFile, "Data File with Request/Response map. No validation will be carried out.")
-	flag.BoolVar(&checkUp, "checkUp", true, "Check it out that FastCGI is up and running through a HEAD request.")
+	flag.BoolVar(&checkUp, "checkUp", true, "Check it out that FastCGI is up and running, and its map ready, through its readyz endpoint.")
 	flag.BoolVar(&gzipOn, "gzipOn", true, "Activate GZIP by adding specific header to the request. That might make all tests fail")
 	flag.Uint64Var(&goroutinesMax, "goroutinesMax", uint64(3*runtime.NumCPU()), "Maximum number of goroutines in parallel in order to avoid hoarding too much resources.")
 	flag.Parse()
@@ -67,7 +70,7 @@ func TestRequests(t *testing.T) {
 	t.Log("-queryStr=" + queryStr)
 	// depends on your test configuration
 	t.Log("-dataFile=" + dataFile)
-	// depends if the server under test supports HEAD queries
+	// depends if the server under test supports readiness queries
 	t.Logf("-checkUp=%t\n", checkUp)
 	// depends if the server under test supports GZIP
 	t.Logf("-gzipOn=s%t\n", gzipOn)
@@ -76,15 +79,26 @@ func TestRequests(t *testing.T) {
 
 	// call that fastcgi to checkout whether it's up or not
 	if checkUp {
-		ping, err := http.Head(queryStr)
+		// readiness probe next to the testing end, as http://0.0.0.0/testingEnd/readyz, whatever its query
+		probe, err := url.Parse(queryStr)
 		if err != nil {
-			t.Error("Unable to request for HEAD info to the server. " + err.Error())
+			t.Error("Unable to parse queryStr. " + err.Error())
 			t.FailNow()
 		}
+		probe.Path = strings.TrimSuffix(probe.Path, "/") + "/" + jsonmock.ReadySegment
+		probe.RawQuery, probe.ForceQuery = "", false
+		ping, err := http.Get(probe.String())
+		if err != nil {
+			t.Error("Unable to request for readiness to the server. " + err.Error())
+			t.FailNow()
+		}
+		details, _ := ioutil.ReadAll(ping.Body)
+		ping.Body.Close()
 		if ping.StatusCode != http.StatusOK {
-			t.Error("Probably FastCGI down: " + ping.Status)
+			t.Error("Probably FastCGI down or its map not ready: " + ping.Status + " " + string(details))
 			t.FailNow()
 		}
+		t.Log("Ready: " + string(details))
 	}
 	// grab the real queries to launch
 	dataMap, err := ioutil.ReadFile(dataFile)
@@ -116,7 +130,7 @@ func TestRequests(t *testing.T) {
 		var rr ReqRes
 		rr.Qry = ""
 		err = dec.Decode(&rr)
-		if err != nil || rr.Req == nil || rr.Res == nil {
+		if err != nil || rr.Req == nil || (rr.Res == nil && rr.Body == nil && len(rr.BodyFile) == 0 && len(rr.BodyBase64) == 0) {
 			t.Error("Unable to process Request Response object.")
 			t.FailNow()
 		}
@@ -220,7 +234,7 @@ func checkRequest(current uint64, goroutinesRunning uint64, t *testing.T, rr *Re
 	responseStr := string(res)
 
 	// what it's read from the file
-	expected, err := toString(rr.Res)
+	expected, err := expectedResponse(rr)
 	if err != nil {
 		t.Errorf("<%d:%d> ["+query+"]"+req+": "+err.Error()+"\n", current, goroutinesRunning)
 		atomic.AddUint64(failed, 1)
@@ -238,7 +252,27 @@ func checkRequest(current uint64, goroutinesRunning uint64, t *testing.T, rr *Re
 	return
 }
 
-// Already defined at JsonMock
+// json response or non-json body, as it must be received
+func expectedResponse(rr *ReqRes) (string, error) {
+	switch {
+	case rr.Res != nil:
+		return toString(rr.Res)
+	case rr.Body != nil:
+		return *rr.Body, nil
+	case len(rr.BodyBase64) > 0:
+		raw, err := base64.StdEncoding.DecodeString(rr.BodyBase64)
+		return string(raw), err
+	default:
+		bodyFile := rr.BodyFile
+		if !filepath.IsAbs(bodyFile) {
+			bodyFile = filepath.Join(filepath.Dir(dataFile), filepath.FromSlash(bodyFile))
+		}
+		raw, err := ioutil.ReadFile(bodyFile)
+		return string(raw), err
+	}
+}
+
+// Already defined at jsonmock package
 // convert into an string
 func toString(raw *json.RawMessage) (string, error) {
 	if raw != nil {
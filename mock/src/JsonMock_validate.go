@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/xue2sheng/postJsonTest/mock/src/jsonmock"
+)
+
+// Subcommand to lint the map without serving it
+const ValidateCommand = "validate"
+
+// Output modes of the validate subcommand
+const (
+	OutputSummary = "summary"
+	OutputKeys    = "keys"
+	OutputJson    = "json"
+)
+
+// validate the map as the server would do at startup and report it; returns the exit code
+func validateCommand(args []string) int {
+
+	flags := flag.NewFlagSet(ValidateCommand, flag.ExitOnError)
+	config := jsonmock.Config{
+		MapFile:            defaultDataFile(jsonmock.DefaultMapFile),
+		RequestSchemaFile:  defaultDataFile(jsonmock.DefaultRequestSchemaFile),
+		ResponseSchemaFile: defaultDataFile(jsonmock.DefaultResponseSchemaFile),
+	}
+	configFlags(flags, &config)
+	output := flags.String("output", OutputSummary, "Output mode: '"+OutputSummary+"', '"+OutputKeys+"' to print the lookup key of every valid entry or '"+OutputJson+"' for the whole report.")
+	flags.Usage = func() {
+		fmt.Println()
+		fmt.Println("Usage: " + os.Args[0] + " " + ValidateCommand + " -config=<ConfigFile> -profile=<Profile> -map=<MockRequestResponseFile> -req=<RequestJsonSchema> -res=<ResponseJsonSchema> -schemas=<SchemaDir> -output=<Output> -debug=<ForcedDebug>")
+		fmt.Println()
+		fmt.Println("Validates every entry at map and computes its lookup key without opening any listener.")
+		fmt.Println("Exit code is not zero when any entry is invalid or duplicated.")
+		fmt.Println("Options are taken as well from " + ConfigEnvPrefix + "<OPTION> environment variables and the configuration file, as the server does.")
+		fmt.Println("When that configuration file has endpoints, the map of every one of them is validated.")
+		fmt.Println()
+		flags.PrintDefaults()
+	}
+	endpoints, err := parseOptions(flags, args, false)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+
+	if *output != OutputSummary && *output != OutputKeys && *output != OutputJson {
+		fmt.Println("Unknown output mode: " + *output)
+		flags.Usage()
+		return 2
+	}
+
+	targets, err := endpointTargets(config, endpoints)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+
+	code := 0
+	reports := make(map[string]jsonmock.ValidationReport)
+	for _, target := range targets {
+		report, err := jsonmock.Validate(target.Config)
+		reports[target.Name] = report
+		if len(target.Name) > 0 && *output != OutputJson {
+			fmt.Println("endpoint " + target.Name + " at " + target.Prefix + ":")
+		}
+
+		switch *output {
+		case OutputJson:
+		case OutputKeys:
+			// what a request must look like to match: [ordered query;optional=...;ignore=...]<header predicates>compacted body,
+			// reqSchema=compacted Json Schema instead of that body when matching by schema, just default for the default entry
+			for _, entry := range report.Entries {
+				if entry.Status == jsonmock.EntryValid {
+					fmt.Printf("%d\t%s\n", entry.Index, entry.Key)
+				}
+			}
+		default:
+			printSummary(report)
+		}
+
+		if err != nil {
+			fmt.Println(err)
+			code = 1
+		} else if !report.Clean() {
+			code = 1
+		}
+	}
+
+	// a single report, or every endpoint report by name
+	if *output == OutputJson {
+		var err error
+		if len(endpoints) == 0 {
+			err = jsonmock.WriteReport(reports[""], "-")
+		} else {
+			err = writeEndpointReports(reports)
+		}
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+	return code
+}
+
+// the map of the server, or the ones of its endpoints by name
+func endpointTargets(config jsonmock.Config, endpoints map[string]map[string]string) ([]EndpointOptions, error) {
+
+	targets := []EndpointOptions{{Config: config}}
+	if len(endpoints) > 0 {
+		targets = targets[:0]
+		names := make([]string, 0, len(endpoints))
+		for name := range endpoints {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			endpoint, err := endpointOptions(name, config, endpoints[name])
+			if err != nil {
+				return nil, err
+			}
+			targets = append(targets, endpoint)
+		}
+	}
+	for i := range targets {
+		if len(targets[i].Config.SchemaDir) == 0 {
+			targets[i].Config.SchemaDir = filepath.Dir(targets[i].Config.RequestSchemaFile)
+		}
+	}
+	return targets, nil
+}
+
+// invalid and duplicated entries along with the counters
+func printSummary(report jsonmock.ValidationReport) {
+	for _, entry := range report.Entries {
+		if entry.Status == jsonmock.EntryValid {
+			continue
+		}
+		fmt.Printf("entry %d %s:\n", entry.Index, entry.Status)
+		for _, desc := range entry.Errors {
+			fmt.Println("  - " + desc)
+		}
+	}
+	fmt.Printf("%s: %d total, %d valid, %d invalid, %d duplicated\n", report.File, report.Total, report.Valid, report.Invalid, report.Duplicated)
+}
+
+// json object with the report of every endpoint by name
+func writeEndpointReports(reports map[string]jsonmock.ValidationReport) error {
+	content, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(append(content, '\n'))
+	return err
+}
@@ -0,0 +1,286 @@
+package jsonmock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Pricing rules of the synthetic bidder
+const (
+	PricingFixed    = "fixed"
+	PricingBidfloor = "bidfloor"
+	PricingRandom   = "random"
+)
+
+// Currency of bids and floors when OpenRTB doesn't tell any
+const DefaultCurrency = "USD"
+
+// How every bid is priced
+type BidderPricing struct {
+	Rule       string  `json:"rule"`
+	Price      float64 `json:"price,omitempty"`
+	Multiplier float64 `json:"multiplier,omitempty"`
+	Min        float64 `json:"min,omitempty"`
+	Max        float64 `json:"max,omitempty"`
+}
+
+// Creative to pick up from the pool
+type BidderCreative struct {
+	Crid    string   `json:"crid"`
+	Adid    string   `json:"adid,omitempty"`
+	Adm     string   `json:"adm,omitempty"`
+	Nurl    string   `json:"nurl,omitempty"`
+	Burl    string   `json:"burl,omitempty"`
+	Adomain []string `json:"adomain,omitempty"`
+	Cat     []string `json:"cat,omitempty"`
+	W       int      `json:"w,omitempty"`
+	H       int      `json:"h,omitempty"`
+}
+
+// Synthetic bidder configuration
+type BidderConfig struct {
+	Seat      string           `json:"seat"`
+	Cur       string           `json:"cur"`
+	Latency   string           `json:"latency,omitempty"`
+	Seed      int64            `json:"seed,omitempty"`
+	Pricing   BidderPricing    `json:"pricing"`
+	Creatives []BidderCreative `json:"creatives"`
+}
+
+// Rule-driven OpenRTB counterparty: one bid per imp
+type Bidder struct {
+	config  BidderConfig
+	latency time.Duration
+	schema  *gojsonschema.Schema
+}
+
+// the bits of an OpenRTB bid request the bidder cares about
+type bidRequest struct {
+	Id   string   `json:"id"`
+	Tmax int      `json:"tmax,omitempty"`
+	Cur  []string `json:"cur,omitempty"`
+	Bcat []string `json:"bcat,omitempty"`
+	Badv []string `json:"badv,omitempty"`
+	Imp  []struct {
+		Id          string  `json:"id"`
+		Bidfloor    float64 `json:"bidfloor,omitempty"`
+		Bidfloorcur string  `json:"bidfloorcur,omitempty"`
+		Banner      *struct {
+			W int `json:"w,omitempty"`
+			H int `json:"h,omitempty"`
+		} `json:"banner,omitempty"`
+	} `json:"imp"`
+}
+
+type bid struct {
+	Id      string   `json:"id"`
+	Impid   string   `json:"impid"`
+	Price   float64  `json:"price"`
+	Adid    string   `json:"adid,omitempty"`
+	Nurl    string   `json:"nurl,omitempty"`
+	Burl    string   `json:"burl,omitempty"`
+	Adm     string   `json:"adm,omitempty"`
+	Adomain []string `json:"adomain,omitempty"`
+	Cat     []string `json:"cat,omitempty"`
+	Crid    string   `json:"crid,omitempty"`
+	W       int      `json:"w,omitempty"`
+	H       int      `json:"h,omitempty"`
+}
+
+type seatBid struct {
+	Seat string `json:"seat,omitempty"`
+	Bid  []bid  `json:"bid"`
+}
+
+type bidResponse struct {
+	Id      string    `json:"id"`
+	Bidid   string    `json:"bidid,omitempty"`
+	Cur     string    `json:"cur,omitempty"`
+	Seatbid []seatBid `json:"seatbid"`
+}
+
+// read the bidder configuration; its responses must comply with the response Json Schema
+func newBidder(configFile string, schema *gojsonschema.Schema) (*Bidder, error) {
+
+	content, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	bidder := &Bidder{schema: schema}
+	if err = json.Unmarshal(content, &bidder.config); err != nil {
+		return nil, errors.New(configFile + ": " + err.Error())
+	}
+
+	if len(bidder.config.Cur) == 0 {
+		bidder.config.Cur = DefaultCurrency
+	}
+	if len(bidder.config.Creatives) == 0 {
+		return nil, errors.New(configFile + ": empty creative pool")
+	}
+	switch bidder.config.Pricing.Rule {
+	case PricingFixed, PricingBidfloor:
+	case PricingRandom:
+		if bidder.config.Pricing.Max < bidder.config.Pricing.Min {
+			return nil, errors.New(configFile + ": random pricing with max below min")
+		}
+	default:
+		return nil, errors.New(configFile + ": unknown pricing rule " + bidder.config.Pricing.Rule)
+	}
+	if len(bidder.config.Latency) > 0 {
+		if bidder.latency, err = time.ParseDuration(bidder.config.Latency); err != nil {
+			return nil, errors.New(configFile + ": " + err.Error())
+		}
+	}
+	return bidder, nil
+}
+
+// answer a bid request; no bid at all means an empty response
+func (b *Bidder) Bid(body string) (string, error) {
+
+	var request bidRequest
+	if err := json.Unmarshal([]byte(body), &request); err != nil {
+		return "", err
+	}
+
+	// honor tmax: too slow means no bid
+	if b.latency > 0 {
+		if request.Tmax > 0 && b.latency >= time.Duration(request.Tmax)*time.Millisecond {
+			return "", nil
+		}
+		time.Sleep(b.latency)
+	}
+
+	// honor cur: only the configured currency is supported
+	if len(request.Cur) > 0 && !contains(request.Cur, b.config.Cur) {
+		return "", nil
+	}
+
+	// same request, same bids
+	hash := fnv.New64a()
+	hash.Write([]byte(body))
+	rnd := rand.New(rand.NewSource(b.config.Seed ^ int64(hash.Sum64())))
+
+	bids := []bid{}
+	for _, imp := range request.Imp {
+		// floors in another currency can't be compared, OpenRTB ones being in USD unless told otherwise
+		floor := imp.Bidfloor
+		floorCur := imp.Bidfloorcur
+		if len(floorCur) == 0 {
+			floorCur = DefaultCurrency
+		}
+		if floor > 0 && floorCur != b.config.Cur {
+			continue
+		}
+
+		price := b.price(floor, rnd)
+		if price <= 0 || price < floor {
+			continue
+		}
+
+		w, h := 0, 0
+		if imp.Banner != nil {
+			w, h = imp.Banner.W, imp.Banner.H
+		}
+		creative, found := b.creative(request.Bcat, request.Badv, w, h, rnd)
+		if !found {
+			continue
+		}
+
+		bids = append(bids, bid{
+			Id:      request.Id + "-" + imp.Id,
+			Impid:   imp.Id,
+			Price:   price,
+			Adid:    creative.Adid,
+			Nurl:    creative.Nurl,
+			Burl:    creative.Burl,
+			Adm:     creative.Adm,
+			Adomain: creative.Adomain,
+			Cat:     creative.Cat,
+			Crid:    creative.Crid,
+			W:       creative.W,
+			H:       creative.H,
+		})
+	}
+	if len(bids) == 0 {
+		return "", nil
+	}
+
+	response := bidResponse{
+		Id:      request.Id,
+		Bidid:   strconv.FormatInt(rnd.Int63(), 36),
+		Cur:     b.config.Cur,
+		Seatbid: []seatBid{{Seat: b.config.Seat, Bid: bids}},
+	}
+	// ad markup as it is, without escaping html
+	buffer := new(bytes.Buffer)
+	enc := json.NewEncoder(buffer)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(response); err != nil {
+		return "", err
+	}
+	content := strings.TrimSpace(buffer.String())
+
+	if errs := schemaErrors(b.schema, content); len(errs) > 0 {
+		return "", errors.New("Bidder response doesn't comply with its Json Schema: " + errs[0])
+	}
+	return content, nil
+}
+
+// price of a bid according to the pricing rule
+func (b *Bidder) price(floor float64, rnd *rand.Rand) float64 {
+
+	pricing := b.config.Pricing
+	var price float64
+	switch pricing.Rule {
+	case PricingFixed:
+		price = pricing.Price
+	case PricingBidfloor:
+		if floor > 0 {
+			price = floor * pricing.Multiplier
+		} else {
+			price = pricing.Price
+		}
+	case PricingRandom:
+		price = pricing.Min + rnd.Float64()*(pricing.Max-pricing.Min)
+	}
+	return math.Round(price*10000) / 10000
+}
+
+// any creative of the pool not blocked and fitting that size
+func (b *Bidder) creative(bcat []string, badv []string, w int, h int, rnd *rand.Rand) (BidderCreative, bool) {
+
+	eligible := []BidderCreative{}
+	for _, creative := range b.config.Creatives {
+		if intersects(creative.Cat, bcat) || intersects(creative.Adomain, badv) {
+			continue
+		}
+		if (w > 0 && creative.W > 0 && creative.W != w) || (h > 0 && creative.H > 0 && creative.H != h) {
+			continue
+		}
+		eligible = append(eligible, creative)
+	}
+	if len(eligible) == 0 {
+		return BidderCreative{}, false
+	}
+	return eligible[rnd.Intn(len(eligible))], true
+}
+
+// any value in common
+func intersects(values []string, blocked []string) bool {
+	for _, value := range values {
+		if contains(blocked, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// OpenRTB bid response as far as the tests care
+type testBidResponse struct {
+	Id      string `json:"id"`
+	Cur     string `json:"cur"`
+	Seatbid []struct {
+		Seat string `json:"seat"`
+		Bid  []struct {
+			Impid string  `json:"impid"`
+			Price float64 `json:"price"`
+			Crid  string  `json:"crid"`
+		} `json:"bid"`
+	} `json:"seatbid"`
+}
+
+// bidder configuration written to a temporary file, removed by the returned func
+func testBidderFile(t *testing.T, config string) (string, func()) {
+
+	dir, err := ioutil.TempDir("", "bidder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "bidder.json")
+	if err = ioutil.WriteFile(file, []byte(config), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return file, func() { os.RemoveAll(dir) }
+}
+
+// bidder of that configuration, its responses valid against that schema
+func testBidder(t *testing.T, config string, schema string) *Bidder {
+
+	file, remove := testBidderFile(t, config)
+	defer remove()
+	compiled, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bidder, err := newBidder(file, compiled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bidder
+}
+
+// bid response to that request, nil on no bid
+func testBid(t *testing.T, bidder *Bidder, request string) *testBidResponse {
+
+	content, err := bidder.Bid(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(content) == 0 {
+		return nil
+	}
+	var response testBidResponse
+	if err = json.Unmarshal([]byte(content), &response); err != nil {
+		t.Fatal(err)
+	}
+	return &response
+}
+
+const testBidderCreatives = `"creatives": [{"crid": "c1", "adomain": ["good.com"], "cat": ["IAB1"]}, {"crid": "c2", "adomain": ["bad.com"], "cat": ["IAB25"]}]`
+
+func TestBidderPricing(t *testing.T) {
+
+	cases := []struct {
+		pricing  string
+		floor    string
+		min, max float64
+	}{
+		{`{"rule": "fixed", "price": 1.5}`, "", 1.5, 1.5},
+		{`{"rule": "fixed", "price": 1.5}`, `, "bidfloor": 2`, 0, 0},
+		{`{"rule": "bidfloor", "multiplier": 1.2, "price": 0.7}`, `, "bidfloor": 2`, 2.4, 2.4},
+		{`{"rule": "bidfloor", "multiplier": 1.2, "price": 0.7}`, "", 0.7, 0.7},
+		{`{"rule": "random", "min": 1, "max": 2}`, "", 1, 2},
+	}
+	for _, c := range cases {
+		bidder := testBidder(t, `{"seat": "s", "pricing": `+c.pricing+`, `+testBidderCreatives+`}`, `{}`)
+		request := `{"id": "r", "imp": [{"id": "1"` + c.floor + `}]}`
+		response := testBid(t, bidder, request)
+		if c.max == 0 {
+			if response != nil {
+				t.Errorf("%s %s: expected no bid under the floor, got %+v", c.pricing, c.floor, response)
+			}
+			continue
+		}
+		if response == nil || len(response.Seatbid) != 1 || len(response.Seatbid[0].Bid) != 1 {
+			t.Fatalf("%s %s: expected a single bid, got %+v", c.pricing, c.floor, response)
+		}
+		if price := response.Seatbid[0].Bid[0].Price; price < c.min || price > c.max {
+			t.Errorf("%s %s: got price %v, expected between %v and %v", c.pricing, c.floor, price, c.min, c.max)
+		}
+		if again := testBid(t, bidder, request); again.Seatbid[0].Bid[0].Price != response.Seatbid[0].Bid[0].Price {
+			t.Errorf("%s: expected the same price for the same request", c.pricing)
+		}
+	}
+}
+
+func TestBidderBlocking(t *testing.T) {
+
+	bidder := testBidder(t, `{"seat": "s", "pricing": {"rule": "fixed", "price": 1}, `+testBidderCreatives+`}`, `{}`)
+	for _, blocking := range []string{`"bcat": ["IAB25"]`, `"badv": ["bad.com"]`} {
+		for i := 0; i < 10; i++ {
+			response := testBid(t, bidder, `{"id": "r`+strconv.Itoa(i)+`", `+blocking+`, "imp": [{"id": "1"}]}`)
+			if response == nil || response.Seatbid[0].Bid[0].Crid != "c1" {
+				t.Errorf("%s: expected creative c1, got %+v", blocking, response)
+			}
+		}
+	}
+	if response := testBid(t, bidder, `{"id": "r", "bcat": ["IAB25"], "badv": ["good.com"], "imp": [{"id": "1"}]}`); response != nil {
+		t.Errorf("expected no bid with every creative blocked, got %+v", response)
+	}
+}
+
+func TestBidderCurrency(t *testing.T) {
+
+	bidder := testBidder(t, `{"seat": "s", "cur": "EUR", "pricing": {"rule": "bidfloor", "multiplier": 2}, `+testBidderCreatives+`}`, `{}`)
+	if response := testBid(t, bidder, `{"id": "r", "cur": ["USD"], "imp": [{"id": "1", "bidfloor": 1, "bidfloorcur": "EUR"}]}`); response != nil {
+		t.Errorf("expected no bid in a currency not asked for, got %+v", response)
+	}
+	if response := testBid(t, bidder, `{"id": "r", "imp": [{"id": "1", "bidfloor": 1}]}`); response != nil {
+		t.Errorf("expected no bid on a floor in USD by default, got %+v", response)
+	}
+	response := testBid(t, bidder, `{"id": "r", "cur": ["USD", "EUR"], "imp": [{"id": "1", "bidfloor": 1, "bidfloorcur": "EUR"}]}`)
+	if response == nil || response.Cur != "EUR" || response.Seatbid[0].Bid[0].Price != 2 {
+		t.Errorf("expected a bid of 2 EUR, got %+v", response)
+	}
+}
+
+func TestBidderTmax(t *testing.T) {
+
+	bidder := testBidder(t, `{"seat": "s", "latency": "30ms", "pricing": {"rule": "fixed", "price": 1}, `+testBidderCreatives+`}`, `{}`)
+	if response := testBid(t, bidder, `{"id": "r", "tmax": 10, "imp": [{"id": "1"}]}`); response != nil {
+		t.Errorf("expected no bid slower than tmax, got %+v", response)
+	}
+	if response := testBid(t, bidder, `{"id": "r", "tmax": 200, "imp": [{"id": "1"}]}`); response == nil {
+		t.Error("expected a bid within tmax")
+	}
+}
+
+func TestBidderSchema(t *testing.T) {
+
+	schema := `{"type": "object", "required": ["id", "cur", "seatbid"], "properties": {"cur": {"enum": ["USD"]},
+		"seatbid": {"type": "array", "minItems": 1, "items": {"required": ["seat", "bid"], "properties": {"bid": {"type": "array",
+		"items": {"required": ["id", "impid", "price", "crid"], "properties": {"price": {"type": "number", "minimum": 0}}}}}}}}}`
+	bidder := testBidder(t, `{"seat": "s", "pricing": {"rule": "fixed", "price": 1}, `+testBidderCreatives+`}`, schema)
+	if response := testBid(t, bidder, `{"id": "r", "imp": [{"id": "1"}, {"id": "2"}]}`); response == nil || len(response.Seatbid[0].Bid) != 2 {
+		t.Errorf("expected a bid per imp, got %+v", response)
+	}
+
+	strict := testBidder(t, `{"seat": "s", "pricing": {"rule": "fixed", "price": 1}, `+testBidderCreatives+`}`, `{"required": ["nbr"]}`)
+	if _, err := strict.Bid(`{"id": "r", "imp": [{"id": "1"}]}`); err == nil || !strings.Contains(err.Error(), "Json Schema") {
+		t.Errorf("expected a response not complying with its Json Schema refused, got %v", err)
+	}
+}
+
+func TestServerBidderWithoutEntries(t *testing.T) {
+
+	file, remove := testBidderFile(t, `{"seat": "s", "pricing": {"rule": "fixed", "price": 1}, `+testBidderCreatives+`}`)
+	defer remove()
+	server, err := NewTestServer(Config{BidderConfigFile: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	if status, _, body := testQuery(t, server, "", `{"id": "r", "imp": [{"id": "1"}]}`, nil); status != http.StatusOK || !strings.Contains(body, `"seatbid"`) {
+		t.Errorf("got %d %q, expected a bid without any entry", status, body)
+	}
+	if status, _, _ := testQuery(t, server, "id=1", "", nil); status != http.StatusNoContent {
+		t.Errorf("got %d, expected 204 without any entry nor body to bid on", status)
+	}
+	if !server.Mock.Readiness().Ready {
+		t.Error("expected a bidder without entries ready")
+	}
+}
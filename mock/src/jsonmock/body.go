@@ -0,0 +1,167 @@
+package jsonmock
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Content type of the usual "res" json responses
+const JsonContentType = "application/json"
+
+// Formats to validate non-json response bodies
+const (
+	FormatText = "text"
+	FormatXml  = "xml"
+	FormatVast = "vast"
+)
+
+// Non-json response of an entry: inline text, a file or base64 encoded bytes
+type ResponseBody struct {
+	Body        *string `json:"body,omitempty"`
+	BodyFile    string  `json:"bodyFile,omitempty"`
+	BodyBase64  string  `json:"bodyBase64,omitempty"`
+	ContentType string  `json:"contentType,omitempty"`
+	Format      string  `json:"format,omitempty"`
+}
+
+// content and content type of that body; relative files are taken from baseDir
+func (b *ResponseBody) load(baseDir string) (string, string, error) {
+
+	var content string
+	contentType := b.ContentType
+
+	switch {
+	case b.Body != nil:
+		content = *b.Body
+		if len(contentType) == 0 {
+			contentType = "text/plain; charset=utf-8"
+		}
+	case len(b.BodyFile) > 0:
+		bodyFile := b.BodyFile
+		if !filepath.IsAbs(bodyFile) {
+			bodyFile = filepath.Join(baseDir, filepath.FromSlash(bodyFile))
+		}
+		raw, err := ioutil.ReadFile(bodyFile)
+		if err != nil {
+			return "", "", err
+		}
+		content = string(raw)
+		if len(contentType) == 0 {
+			contentType = mime.TypeByExtension(filepath.Ext(bodyFile))
+		}
+	case len(b.BodyBase64) > 0:
+		raw, err := base64.StdEncoding.DecodeString(b.BodyBase64)
+		if err != nil {
+			return "", "", err
+		}
+		content = string(raw)
+	default:
+		return "", "", errors.New("no body, bodyFile or bodyBase64")
+	}
+
+	if len(contentType) == 0 {
+		switch b.Format {
+		case FormatXml, FormatVast:
+			contentType = "application/xml"
+		case FormatText:
+			contentType = "text/plain; charset=utf-8"
+		default:
+			contentType = "application/octet-stream"
+		}
+	}
+	return content, contentType, nil
+}
+
+// errors of the body according to its format, none when valid or without any format
+func (b *ResponseBody) validate(content string) []string {
+
+	var err error
+	switch b.Format {
+	case "":
+		return nil
+	case FormatText:
+		if strings.ContainsRune(content, '\x00') {
+			err = errors.New("binary content")
+		}
+	case FormatXml:
+		err = wellFormedXml(content)
+	case FormatVast:
+		err = wellFormedXml(content)
+		if err == nil {
+			err = structuralVast(content)
+		}
+	default:
+		err = errors.New("unknown format " + b.Format)
+	}
+
+	if err != nil {
+		return []string{err.Error()}
+	}
+	return nil
+}
+
+// xml well-formedness: every token can be read and every element is closed
+func wellFormedXml(content string) error {
+
+	dec := xml.NewDecoder(strings.NewReader(content))
+	roots := 0
+	depth := 0
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch token.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				roots++
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	if roots != 1 {
+		return errors.New("xml must have exactly one root element")
+	}
+	return nil
+}
+
+// XSD-free check of the VAST skeleton: versioned root and every Ad either InLine or Wrapper
+func structuralVast(content string) error {
+
+	type vastAd struct {
+		Id      string    `xml:"id,attr"`
+		InLine  *struct{} `xml:"InLine"`
+		Wrapper *struct{} `xml:"Wrapper"`
+	}
+	type vastDocument struct {
+		XMLName xml.Name `xml:"VAST"`
+		Version string   `xml:"version,attr"`
+		Ads     []vastAd `xml:"Ad"`
+	}
+
+	var vast vastDocument
+	if err := xml.Unmarshal([]byte(content), &vast); err != nil {
+		return err
+	}
+	if len(vast.Version) == 0 {
+		return errors.New("VAST without version attribute")
+	}
+	for i, ad := range vast.Ads {
+		if (ad.InLine == nil) == (ad.Wrapper == nil) {
+			return errors.New("VAST Ad " + ad.Id + " at position " + strconv.Itoa(i+1) + " must be either InLine or Wrapper")
+		}
+	}
+	return nil
+}
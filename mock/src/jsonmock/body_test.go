@@ -0,0 +1,80 @@
+package jsonmock
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResponseBody(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	xmlFile := filepath.Join(dir, "ad.xml")
+	if err = ioutil.WriteFile(xmlFile, []byte(`<ad id="1"/>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	vast := `<VAST version="3.0"><Ad id="1"><InLine/></Ad><Ad id="2"><Wrapper/></Ad></VAST>`
+	binary := "\x89PNG\x00\x01"
+	text := func(value string) *string { return &value }
+	cases := []struct {
+		body        ResponseBody
+		valid       bool
+		contentType string
+		response    string
+	}{
+		{ResponseBody{BodyFile: xmlFile, Format: FormatXml}, true, mime.TypeByExtension(".xml"), `<ad id="1"/>`},
+		{ResponseBody{BodyBase64: base64.StdEncoding.EncodeToString([]byte(binary))}, true, "application/octet-stream", binary},
+		{ResponseBody{Body: text(vast), Format: FormatVast}, true, "text/plain; charset=utf-8", vast},
+		{ResponseBody{BodyBase64: base64.StdEncoding.EncodeToString([]byte(vast)), Format: FormatVast, ContentType: "application/xml"}, true, "application/xml", vast},
+		{ResponseBody{BodyFile: filepath.Join(dir, "missing.xml")}, false, "", ""},
+		{ResponseBody{BodyBase64: "not base64!"}, false, "", ""},
+		{ResponseBody{Body: text("<ad><id>1</ad>"), Format: FormatXml}, false, "", ""},
+		{ResponseBody{Body: text("<ad/><ad/>"), Format: FormatXml}, false, "", ""},
+		{ResponseBody{Body: text(`<VAST><Ad><InLine/></Ad></VAST>`), Format: FormatVast}, false, "", ""},
+		{ResponseBody{Body: text(`<VAST version="3.0"><Ad id="1"/></VAST>`), Format: FormatVast}, false, "", ""},
+		{ResponseBody{Body: text(`<VAST version="3.0"><Ad id="1"><InLine/><Wrapper/></Ad></VAST>`), Format: FormatVast}, false, "", ""},
+		{ResponseBody{Body: text(`<html/>`), Format: FormatVast}, false, "", ""},
+		{ResponseBody{Body: text(binary), Format: FormatText}, false, "", ""},
+	}
+	entries := []Entry{}
+	for i, c := range cases {
+		entries = append(entries, Entry{Query: "case=" + string(rune('a'+i)), ResponseBody: c.body})
+	}
+	server, err := NewTestServer(Config{Entries: entries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	report := server.Mock.Report()
+	for i, c := range cases {
+		if valid := report.Entries[i].Status == EntryValid; valid != c.valid {
+			t.Errorf("case %d: got %s %v, expected valid %t", i, report.Entries[i].Status, report.Entries[i].Errors, c.valid)
+			continue
+		}
+		status, contentType, response := testQuery(t, server, "case="+string(rune('a'+i)), "", nil)
+		if !c.valid {
+			if status != http.StatusNoContent {
+				t.Errorf("case %d: got %d, expected an invalid entry never served", i, status)
+			}
+			continue
+		}
+		if status != http.StatusOK || contentType != c.contentType || response != c.response {
+			t.Errorf("case %d: got %d %s %q, expected %s %q", i, status, contentType, response, c.contentType, c.response)
+		}
+	}
+
+	// unknown formats don't even get through the map format
+	if _, err = NewServer(Config{Entries: []Entry{{Query: "yaml", ResponseBody: ResponseBody{Body: text("pong"), Format: "yaml"}}}}); err == nil {
+		t.Error("expected an unknown format refused")
+	}
+}
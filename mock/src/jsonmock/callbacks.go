@@ -0,0 +1,128 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notices the mock can fire after serving a bid response, as an exchange would do
+const (
+	CallbackNurl = "nurl"
+	CallbackBurl = "burl"
+)
+
+// Maximum time to wait for every notice
+const CallbackTimeout = 5 * time.Second
+
+// Exchange-side stand-in firing win and billing notices of served bid responses
+type Notifier struct {
+	kinds       map[string]bool
+	delay       time.Duration
+	probability float64
+	client      *http.Client
+	rnd         *rand.Rand
+	rndMutex    sync.Mutex
+	pending     sync.WaitGroup
+}
+
+// notices to fire, as "nurl,burl", after a delay and with a probability between 0 and 1
+func newNotifier(callbacks string, delay time.Duration, probability float64, seed int64) (*Notifier, error) {
+
+	notifier := &Notifier{
+		kinds:       make(map[string]bool),
+		delay:       delay,
+		probability: probability,
+		client:      &http.Client{Timeout: CallbackTimeout},
+		rnd:         rand.New(rand.NewSource(seed)),
+	}
+	for _, kind := range strings.Split(callbacks, ",") {
+		kind = strings.TrimSpace(kind)
+		switch kind {
+		case "":
+		case CallbackNurl, CallbackBurl:
+			notifier.kinds[kind] = true
+		default:
+			return nil, errors.New("Unknown callback " + kind + ", only " + CallbackNurl + " and " + CallbackBurl + " are supported")
+		}
+	}
+	if probability < 0 || probability > 1 {
+		return nil, errors.New("Callback probability must be between 0 and 1")
+	}
+	if delay < 0 {
+		return nil, errors.New("Callback delay cannot be negative")
+	}
+	return notifier, nil
+}
+
+// fire in the background the notices of every bid at that response, if it's a bid response at all
+func (n *Notifier) Notify(response string, debug bool) {
+
+	var auction bidResponse
+	if json.Unmarshal([]byte(response), &auction) != nil {
+		return
+	}
+
+	for _, seat := range auction.Seatbid {
+		for _, bid := range seat.Bid {
+			macros := strings.NewReplacer(
+				"${AUCTION_ID}", url.QueryEscape(auction.Id),
+				"${AUCTION_BID_ID}", url.QueryEscape(auction.Bidid),
+				"${AUCTION_IMP_ID}", url.QueryEscape(bid.Impid),
+				"${AUCTION_SEAT_ID}", url.QueryEscape(seat.Seat),
+				"${AUCTION_AD_ID}", url.QueryEscape(bid.Adid),
+				"${AUCTION_PRICE}", strconv.FormatFloat(bid.Price, 'f', -1, 64),
+				"${AUCTION_CURRENCY}", url.QueryEscape(auction.Cur),
+			)
+			if n.kinds[CallbackNurl] && len(bid.Nurl) > 0 {
+				n.fire(CallbackNurl, macros.Replace(bid.Nurl), debug)
+			}
+			if n.kinds[CallbackBurl] && len(bid.Burl) > 0 {
+				n.fire(CallbackBurl, macros.Replace(bid.Burl), debug)
+			}
+		}
+	}
+}
+
+// call that url after the delay, unless unlucky
+func (n *Notifier) fire(kind string, callback string, debug bool) {
+
+	n.rndMutex.Lock()
+	lucky := n.rnd.Float64() < n.probability
+	n.rndMutex.Unlock()
+	if !lucky {
+		if debug {
+			log.Println("Skipped " + kind + " " + callback)
+		}
+		return
+	}
+
+	n.pending.Add(1)
+	time.AfterFunc(n.delay, func() {
+		defer n.pending.Done()
+		res, err := n.client.Get(callback)
+		if err != nil {
+			log.Println(kind + ": " + err.Error())
+			return
+		}
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+		if debug {
+			log.Printf("Fired %s %s: %d", kind, callback, res.StatusCode)
+		}
+	})
+}
+
+// wait for every scheduled notice
+func (n *Notifier) Wait() {
+	n.pending.Wait()
+}
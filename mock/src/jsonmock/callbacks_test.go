@@ -0,0 +1,104 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCallbacks(t *testing.T) {
+
+	// local stand-in for the win and billing notices
+	var received []string
+	var receivedMutex sync.Mutex
+	standIn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedMutex.Lock()
+		received = append(received, r.URL.Path+"?"+r.URL.RawQuery)
+		receivedMutex.Unlock()
+	}))
+	defer standIn.Close()
+
+	response := json.RawMessage(`{"id": "auction-1", "bidid": "b1", "cur": "EUR", "seatbid": [ {"seat": "s1", "bid": [
+		{"id": "1", "impid": "imp-1", "price": 1.25, "adid": "ad-1",
+		 "nurl": "` + standIn.URL + `/win?price=${AUCTION_PRICE}&id=${AUCTION_ID}&imp=${AUCTION_IMP_ID}",
+		 "burl": "` + standIn.URL + `/bill?price=${AUCTION_PRICE}&cur=${AUCTION_CURRENCY}&seat=${AUCTION_SEAT_ID}&ad=${AUCTION_AD_ID}&bid=${AUCTION_BID_ID}"} ] } ]}`)
+
+	server, err := NewTestServer(Config{
+		Entries:       []Entry{{Query: "auction", Res: response}, {Query: "nobid", Res: map[string]string{"id": "x"}}},
+		Callbacks:     CallbackNurl + "," + CallbackBurl,
+		CallbackDelay: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{"auction", "nobid"} {
+		res, err := http.Get(server.QueryURL() + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	// shutdown waits for every pending notice
+	server.Close()
+
+	sort.Strings(received)
+	expected := []string{
+		"/bill?price=1.25&cur=EUR&seat=s1&ad=ad-1&bid=b1",
+		"/win?price=1.25&id=auction-1&imp=imp-1",
+	}
+	if len(received) != len(expected) {
+		t.Fatalf("got %v, expected %v", received, expected)
+	}
+	for i := range expected {
+		if received[i] != expected[i] {
+			t.Errorf("got %s, expected %s", received[i], expected[i])
+		}
+	}
+}
+
+func TestCallbacksNever(t *testing.T) {
+
+	var received int32
+	standIn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+	}))
+	defer standIn.Close()
+
+	// lost wins: notices switched off, not turned into always
+	response := json.RawMessage(`{"id": "auction-1", "seatbid": [ {"bid": [ {"id": "1", "impid": "imp-1", "price": 1.25, "nurl": "` + standIn.URL + `/win"} ] } ]}`)
+	never := 0.0
+	server, err := NewTestServer(Config{Entries: []Entry{{Query: "auction", Res: response}}, Callbacks: CallbackNurl, CallbackProbability: &never})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		res, err := http.Get(server.QueryURL() + "auction")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	server.Close()
+
+	if count := atomic.LoadInt32(&received); count != 0 {
+		t.Errorf("got %d notices, expected none with probability 0", count)
+	}
+}
+
+func TestCallbacksConfig(t *testing.T) {
+
+	if _, err := newNotifier("nurl,lurl", 0, 1, 1); err == nil {
+		t.Error("unknown callbacks must fail")
+	}
+	if _, err := newNotifier("nurl", 0, 1.5, 1); err == nil {
+		t.Error("probabilities over 1 must fail")
+	}
+	if _, err := newNotifier("nurl", -time.Second, 1, 1); err == nil {
+		t.Error("negative delays must fail")
+	}
+}
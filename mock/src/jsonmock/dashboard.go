@@ -0,0 +1,212 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// State of a single endpoint as shown by the dashboard
+type DashboardEndpoint struct {
+	Name        string          `json:"name"`
+	Prefix      string          `json:"prefix"`
+	ForcedDebug bool            `json:"forcedDebug"`
+	File        string          `json:"file"`
+	Total       int             `json:"total"`
+	Valid       int             `json:"valid"`
+	Invalid     int             `json:"invalid"`
+	Duplicated  int             `json:"duplicated"`
+	Mappings    []Mapping       `json:"mappings"`
+	Journal     []JournalRecord `json:"journal"`
+}
+
+// Readiness of every endpoint, by name; ready when all of them are
+type DashboardReadiness struct {
+	Ready     bool                 `json:"ready"`
+	Endpoints map[string]Readiness `json:"endpoints"`
+}
+
+// Web UI, to be served from an admin listener, with the mappings, recent requests and validation errors of every endpoint
+type Dashboard struct {
+	endpoints []Endpoint
+	mux       *http.ServeMux
+	started   time.Time
+}
+
+// Dashboard of those endpoints; a single server is just an endpoint at "/"
+func NewDashboard(endpoints []Endpoint) *Dashboard {
+
+	dashboard := &Dashboard{endpoints: endpoints, mux: http.NewServeMux(), started: time.Now()}
+	dashboard.mux.HandleFunc("/", dashboard.page)
+	dashboard.mux.HandleFunc("/api/endpoints", dashboard.state)
+	dashboard.mux.HandleFunc("/api/debug", dashboard.debug)
+	dashboard.mux.HandleFunc("/api/snapshot", dashboard.snapshot)
+	dashboard.mux.HandleFunc("/api/mappings", dashboard.mappings)
+	dashboard.mux.HandleFunc("/"+HealthSegment, dashboard.health)
+	dashboard.mux.HandleFunc("/"+ReadySegment, dashboard.ready)
+	return dashboard
+}
+
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mux.ServeHTTP(w, r)
+}
+
+// the whole UI in a single page, nothing else to download
+func (d *Dashboard) page(w http.ResponseWriter, r *http.Request) {
+
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(dashboardPage)))
+	w.Write([]byte(dashboardPage))
+}
+
+// every endpoint as json
+func (d *Dashboard) state(w http.ResponseWriter, r *http.Request) {
+
+	endpoints := make([]DashboardEndpoint, 0, len(d.endpoints))
+	for _, endpoint := range d.endpoints {
+		report := endpoint.Server.Report()
+		endpoints = append(endpoints, DashboardEndpoint{
+			Name:        endpoint.Name,
+			Prefix:      endpoint.Prefix,
+			ForcedDebug: endpoint.Server.ForcedDebug(),
+			File:        report.File,
+			Total:       report.Total,
+			Valid:       report.Valid,
+			Invalid:     report.Invalid,
+			Duplicated:  report.Duplicated,
+			Mappings:    endpoint.Server.Mappings(),
+			Journal:     endpoint.Server.Journal(),
+		})
+	}
+
+	writeJson(w, endpoints)
+}
+
+// POST /api/debug?endpoint=<name>&forced=<bool> toggles forced debug at runtime
+func (d *Dashboard) debug(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	forced, err := strconv.ParseBool(r.URL.Query().Get("forced"))
+	if err != nil {
+		http.Error(w, "forced: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if server := d.server(w, r); server != nil {
+		server.SetForcedDebug(forced)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GET /api/snapshot?endpoint=<name> downloads the whole state of an endpoint, POST restores it
+func (d *Dashboard) snapshot(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		http.Error(w, "only GET and POST are allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	server := d.server(w, r)
+	if server == nil {
+		return
+	}
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Disposition", "attachment; filename=\"snapshot.json\"")
+		writeJson(w, server.Snapshot())
+		return
+	}
+
+	var snapshot Snapshot
+	if err := json.NewDecoder(r.Body).Decode(&snapshot); err != nil {
+		http.Error(w, "snapshot: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := server.Restore(snapshot); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/mappings?endpoint=<name> adds a json array of entries at runtime, answering their validation outcome
+func (d *Dashboard) mappings(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	server := d.server(w, r)
+	if server == nil {
+		return
+	}
+	var entries []Entry
+	if err := json.NewDecoder(r.Body).Decode(&entries); err != nil {
+		http.Error(w, "entries: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	added, err := server.AddEntries(entries)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	writeJson(w, added)
+}
+
+// the process is alive as long as it answers
+func (d *Dashboard) health(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, Health{Alive: true, Started: d.started, Uptime: time.Since(d.started).String()})
+}
+
+// readiness of every endpoint, 503 unless all of them are ready
+func (d *Dashboard) ready(w http.ResponseWriter, r *http.Request) {
+
+	readiness := DashboardReadiness{Ready: true, Endpoints: make(map[string]Readiness)}
+	for _, endpoint := range d.endpoints {
+		endpointReadiness := endpoint.Server.Readiness()
+		readiness.Endpoints[endpoint.Name] = endpointReadiness
+		readiness.Ready = readiness.Ready && endpointReadiness.Ready
+	}
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJsonStatus(w, status, readiness)
+}
+
+// server of the endpoint named at the query, nil once a 404 is answered
+func (d *Dashboard) server(w http.ResponseWriter, r *http.Request) *Server {
+	name := r.URL.Query().Get("endpoint")
+	for _, endpoint := range d.endpoints {
+		if endpoint.Name == name {
+			return endpoint.Server
+		}
+	}
+	http.Error(w, "no endpoint "+name, http.StatusNotFound)
+	return nil
+}
+
+// value as a json answer
+func writeJson(w http.ResponseWriter, value interface{}) {
+	writeJsonStatus(w, http.StatusOK, value)
+}
+
+func writeJsonStatus(w http.ResponseWriter, status int, value interface{}) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", JsonContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.WriteHeader(status)
+	w.Write(content)
+}
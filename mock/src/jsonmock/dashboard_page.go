@@ -0,0 +1,169 @@
+package jsonmock
+
+// single page dashboard: inline styles and scripts only, so that it works offline
+const dashboardPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>JsonMock</title>
+<style>
+  body { font-family: sans-serif; margin: 0; color: #222; }
+  header { background: #2d3e50; color: #fff; padding: 8px 16px; display: flex; align-items: center; gap: 16px; }
+  header h1 { font-size: 18px; margin: 0; }
+  nav button { background: none; border: none; color: #ccd; cursor: pointer; font-size: 14px; padding: 4px 8px; }
+  nav button.active { color: #fff; border-bottom: 2px solid #fff; }
+  main { padding: 16px; }
+  table { border-collapse: collapse; width: 100%; font-size: 13px; }
+  th, td { border-bottom: 1px solid #ddd; padding: 4px 8px; text-align: left; vertical-align: top; }
+  th { background: #f4f4f4; }
+  .valid, .entry { color: #2a7a2a; }
+  .invalid, .duplicated, .miss, .rejected, .throttled { color: #b22; }
+  .bidder, .generated { color: #a60; }
+  pre { margin: 0; white-space: pre-wrap; word-break: break-all; max-width: 480px; }
+  .summary { margin-bottom: 12px; }
+</style>
+</head>
+<body>
+<header>
+  <h1>JsonMock</h1>
+  <select id="endpoint"></select>
+  <label><input type="checkbox" id="debug"> forced debug</label>
+  <a id="snapshot" href="api/snapshot">snapshot</a>
+  <nav>
+    <button data-view="mappings" class="active">Mappings</button>
+    <button data-view="requests">Requests</button>
+    <button data-view="validation">Validation</button>
+  </nav>
+</header>
+<main>
+  <div class="summary" id="summary"></div>
+  <div id="view"></div>
+</main>
+<script>
+(function () {
+  var endpoints = [], selected = null, view = "mappings";
+
+  function cell(row, text, klass) {
+    var td = document.createElement("td");
+    if (typeof text === "object" && text !== null) {
+      td.appendChild(text);
+    } else {
+      td.textContent = text === undefined || text === null ? "" : String(text);
+    }
+    if (klass) { td.className = klass; }
+    row.appendChild(td);
+  }
+
+  function pre(text) {
+    var element = document.createElement("pre");
+    element.textContent = text;
+    return element;
+  }
+
+  function table(headers, rows) {
+    var result = document.createElement("table"), head = document.createElement("tr");
+    headers.forEach(function (name) {
+      var th = document.createElement("th");
+      th.textContent = name;
+      head.appendChild(th);
+    });
+    result.appendChild(head);
+    rows.forEach(function (row) { result.appendChild(row); });
+    return result;
+  }
+
+  function current() {
+    for (var i = 0; i < endpoints.length; i++) {
+      if (endpoints[i].name === selected) { return endpoints[i]; }
+    }
+    return endpoints[0];
+  }
+
+  function render() {
+    var endpoint = current(), rows = [], container = document.getElementById("view");
+    if (!endpoint) { return; }
+    document.getElementById("debug").checked = endpoint.forcedDebug;
+    document.getElementById("snapshot").href = "api/snapshot?endpoint=" + encodeURIComponent(endpoint.name);
+    document.getElementById("summary").textContent = (endpoint.prefix ? endpoint.prefix + " " : "") + endpoint.file +
+      ": " + endpoint.valid + " valid, " + endpoint.invalid + " invalid, " + endpoint.duplicated + " duplicated";
+
+    if (view === "mappings") {
+      endpoint.mappings.forEach(function (mapping) {
+        var row = document.createElement("tr");
+        cell(row, mapping.index);
+        cell(row, mapping.source);
+        cell(row, mapping.status, mapping.status);
+        cell(row, pre(mapping.key || ""));
+        cell(row, mapping.hits);
+        rows.push(row);
+      });
+      container.replaceChildren(table(["#", "source", "status", "key", "hits"], rows));
+    } else if (view === "requests") {
+      endpoint.journal.forEach(function (record) {
+        var row = document.createElement("tr");
+        cell(row, new Date(record.time).toLocaleTimeString());
+        cell(row, record.method + " " + record.path + (record.query ? "?" + record.query : ""));
+        cell(row, record.status);
+        cell(row, record.match + (record.entry !== undefined ? " " + record.entry : ""), record.match);
+        cell(row, pre(record.explanation || (record.diff || []).join("\n")));
+        cell(row, pre(record.body || ""));
+        rows.push(row);
+      });
+      container.replaceChildren(table(["time", "request", "status", "match", "why", "body"], rows));
+    } else {
+      endpoint.mappings.forEach(function (mapping) {
+        if (!mapping.errors) { return; }
+        var row = document.createElement("tr");
+        cell(row, mapping.index);
+        cell(row, mapping.source);
+        cell(row, mapping.status, mapping.status);
+        cell(row, pre(mapping.errors.join("\n")));
+        rows.push(row);
+      });
+      container.replaceChildren(table(["#", "source", "status", "errors"], rows));
+    }
+  }
+
+  function refresh() {
+    fetch("api/endpoints").then(function (res) { return res.json(); }).then(function (data) {
+      var select = document.getElementById("endpoint");
+      endpoints = data;
+      if (select.options.length !== data.length) {
+        select.replaceChildren();
+        data.forEach(function (endpoint) {
+          var option = document.createElement("option");
+          option.value = endpoint.name;
+          option.textContent = endpoint.name || "default";
+          select.appendChild(option);
+        });
+        select.style.display = data.length > 1 ? "" : "none";
+      }
+      if (selected === null && data.length > 0) { selected = data[0].name; }
+      select.value = selected;
+      render();
+    });
+  }
+
+  document.getElementById("endpoint").addEventListener("change", function (event) {
+    selected = event.target.value;
+    render();
+  });
+  document.getElementById("debug").addEventListener("change", function (event) {
+    fetch("api/debug?endpoint=" + encodeURIComponent(selected) + "&forced=" + event.target.checked, { method: "POST" }).then(refresh);
+  });
+  Array.prototype.forEach.call(document.querySelectorAll("nav button"), function (button) {
+    button.addEventListener("click", function () {
+      Array.prototype.forEach.call(document.querySelectorAll("nav button"), function (other) { other.className = ""; });
+      button.className = "active";
+      view = button.getAttribute("data-view");
+      render();
+    });
+  });
+
+  refresh();
+  setInterval(refresh, 2000);
+})();
+</script>
+</body>
+</html>
+`
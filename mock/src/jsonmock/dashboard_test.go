@@ -0,0 +1,73 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDashboard(t *testing.T) {
+
+	server, err := NewTestServer(Config{Entries: testEntries()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	testQuery(t, server, "id=1", "", nil)
+	testQuery(t, server, "id=1", "", nil)
+	testQuery(t, server, "id=3", "", nil)
+
+	admin := httptest.NewServer(NewDashboard([]Endpoint{{Prefix: "/", Server: server.Mock}}))
+	defer admin.Close()
+
+	res, err := http.Get(admin.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if !strings.Contains(string(page), "<title>JsonMock</title>") || strings.Contains(string(page), "src=\"http") || strings.Contains(string(page), "href=\"http") {
+		t.Error("the dashboard must be a single page without remote assets")
+	}
+
+	res, err = http.Post(admin.URL+"/api/debug?endpoint=&forced=true", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNoContent || !server.Mock.ForcedDebug() {
+		t.Fatalf("got %d, expected forced debug turned on", res.StatusCode)
+	}
+
+	res, err = http.Get(admin.URL + "/api/endpoints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var endpoints []DashboardEndpoint
+	err = json.NewDecoder(res.Body).Decode(&endpoints)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints) != 1 || !endpoints[0].ForcedDebug || endpoints[0].Valid != 4 {
+		t.Fatalf("unexpected endpoints %+v", endpoints)
+	}
+	if mapping := endpoints[0].Mappings[0]; mapping.Source != EntriesFile || mapping.Hits != 2 {
+		t.Errorf("got %+v, expected 2 hits of entry 0 from %s", mapping, EntriesFile)
+	}
+
+	journal := endpoints[0].Journal
+	if len(journal) != 3 {
+		t.Fatalf("got %d requests at the journal, expected 3", len(journal))
+	}
+	miss := journal[0]
+	if miss.Match != MatchMiss || miss.Status != http.StatusNoContent || len(miss.Diff) != 3 || !contains(miss.Diff, "entry 0: param id: expected 1, got 3") {
+		t.Errorf("unexpected miss %+v", miss)
+	}
+	if hit := journal[1]; hit.Match != MatchEntry || hit.Entry == nil || *hit.Entry != 0 || hit.Status != http.StatusOK {
+		t.Errorf("unexpected hit %+v", hit)
+	}
+}
@@ -0,0 +1,125 @@
+package jsonmock
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/fcgi"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FastCGI param NGINX passes with the path of the location, the request path is used otherwise
+const DocumentURIParam = "DOCUMENT_URI"
+
+// Named mock server selected by path prefix
+type Endpoint struct {
+	Name   string
+	Prefix string
+	Server *Server
+}
+
+// Several isolated mock servers in the same process, each one with its own map, schemas and settings
+type EndpointMux struct {
+	endpoints      []Endpoint // longest prefix first
+	endpointsMutex sync.RWMutex
+}
+
+// Empty set of endpoints; every request gets a 404 until some endpoint is added
+func NewEndpointMux() *EndpointMux {
+	return &EndpointMux{}
+}
+
+// Add a named endpoint before serving it; its prefix matches whole path segments, "/" matching everything, and becomes the location of its probes
+func (m *EndpointMux) Add(name string, prefix string, server *Server) error {
+
+	if len(name) == 0 || server == nil {
+		return errors.New("Endpoint without name or server")
+	}
+	prefix = cleanPrefix(prefix)
+
+	m.endpointsMutex.Lock()
+	defer m.endpointsMutex.Unlock()
+	for _, endpoint := range m.endpoints {
+		if endpoint.Name == name {
+			return errors.New("Endpoint " + name + " already added")
+		}
+		if endpoint.Prefix == prefix {
+			return errors.New("Endpoints " + endpoint.Name + " and " + name + " with the same prefix " + prefix)
+		}
+	}
+	server.handler.location = prefix
+	m.endpoints = append(m.endpoints, Endpoint{Name: name, Prefix: prefix, Server: server})
+	sort.SliceStable(m.endpoints, func(i, j int) bool { return len(m.endpoints[i].Prefix) > len(m.endpoints[j].Prefix) })
+	return nil
+}
+
+// Every endpoint, longest prefix first
+func (m *EndpointMux) Endpoints() []Endpoint {
+	m.endpointsMutex.RLock()
+	defer m.endpointsMutex.RUnlock()
+	return append([]Endpoint{}, m.endpoints...)
+}
+
+// absolute path without trailing slash, "/" when empty
+func cleanPrefix(prefix string) string {
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	if len(prefix) > 1 {
+		prefix = strings.TrimSuffix(prefix, "/")
+	}
+	return prefix
+}
+
+// endpoint whose prefix matches that path, the longest one
+func (m *EndpointMux) match(path string) (Endpoint, bool) {
+
+	m.endpointsMutex.RLock()
+	defer m.endpointsMutex.RUnlock()
+	for _, endpoint := range m.endpoints {
+		if endpoint.Prefix == "/" || path == endpoint.Prefix || strings.HasPrefix(path, endpoint.Prefix+"/") {
+			return endpoint, true
+		}
+	}
+	return Endpoint{}, false
+}
+
+// path of a request: DOCUMENT_URI when it comes from NGINX, otherwise REQUEST_URI or SCRIPT_NAME plus PATH_INFO
+func requestPath(r *http.Request) string {
+	if documentURI := fcgi.ProcessEnv(r)[DocumentURIParam]; len(documentURI) > 0 {
+		return documentURI
+	}
+	return r.URL.Path
+}
+
+func (m *EndpointMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	endpoint, found := m.match(requestPath(r))
+	if !found {
+		http.Error(w, "no endpoint for "+requestPath(r), http.StatusNotFound)
+		return
+	}
+	endpoint.Server.Handler().ServeHTTP(w, r)
+}
+
+// Serve FastCGI requests for every endpoint until SIGINT/SIGTERM, then drain in-flight ones
+func (m *EndpointMux) ServeFCGI(listener net.Listener, timeout time.Duration) error {
+	return serveFCGI(listener, m, timeout, m.stop, m.runShutdownHooks)
+}
+
+// stop every endpoint
+func (m *EndpointMux) stop() {
+	for _, endpoint := range m.Endpoints() {
+		endpoint.Server.stop()
+	}
+}
+
+// shutdown hooks of every endpoint
+func (m *EndpointMux) runShutdownHooks() {
+	for _, endpoint := range m.Endpoints() {
+		endpoint.Server.runShutdownHooks()
+	}
+}
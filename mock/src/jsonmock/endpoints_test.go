@@ -0,0 +1,65 @@
+package jsonmock
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEndpointMux(t *testing.T) {
+
+	endpoints := NewEndpointMux()
+	for _, endpoint := range []struct{ name, prefix, ssp string }{
+		{"smaato", "/smaato", "smaato"},
+		{"smaatoV2", "/smaato/v2/", "smaatoV2"},
+		{"other", "other", "other"},
+	} {
+		server, err := NewServer(Config{Entries: []Entry{{Query: "id=1", Res: map[string]string{"ssp": endpoint.ssp}}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = endpoints.Add(endpoint.name, endpoint.prefix, server); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := endpoints.Add("again", "/smaato/", endpoints.Endpoints()[0].Server); err == nil {
+		t.Fatal("the same prefix twice must fail")
+	}
+
+	server := httptest.NewServer(endpoints)
+	defer server.Close()
+
+	cases := []struct {
+		path     string
+		status   int
+		response string
+	}{
+		{"/smaato", http.StatusOK, `{"ssp":"smaato"}`},
+		{"/smaato/v1", http.StatusOK, `{"ssp":"smaato"}`},
+		{"/smaato/v2", http.StatusOK, `{"ssp":"smaatoV2"}`},
+		{"/other/", http.StatusOK, `{"ssp":"other"}`},
+		{"/smaato/v1/" + ReadySegment, http.StatusOK, `{"ssp":"smaato"}`},
+		{"/smaatox", http.StatusNotFound, "no endpoint for /smaatox\n"},
+		{"/", http.StatusNotFound, "no endpoint for /\n"},
+	}
+	for _, c := range cases {
+		res, err := http.Get(server.URL + c.path + "?id=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != c.status || string(content) != c.response {
+			t.Errorf("%s: got %d %q, expected %d %q", c.path, res.StatusCode, content, c.status, c.response)
+		}
+	}
+
+	// probes right after the prefix of every endpoint
+	for _, path := range []string{"/smaato/", "/smaato/v2/", "/other/"} {
+		var readiness Readiness
+		if status := testHealth(t, server.URL+path+ReadySegment+"?id=1", &readiness); status != http.StatusOK || !readiness.Ready || readiness.Valid != 1 {
+			t.Errorf("%s: got %d %+v, expected the readiness of its endpoint", path, status, readiness)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package jsonmock
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"go/format"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Request that matches a valid entry along with the response the mock answers
+type Exchange struct {
+	Index       int
+	Path        string
+	Query       string
+	Headers     http.Header
+	Request     string
+	Response    string
+	ContentType string
+}
+
+// Exchanges of every valid entry with a literal request, in the order they were written; headers only known by a regex
+// get a value matching it, and the entries without any such value are left out and logged
+func Exchanges(config Config) ([]Exchange, ValidationReport, error) {
+
+	config = config.withDefaults()
+	reqJS, resJS, err := loadJsonSchemas(config)
+	if err != nil {
+		return nil, ValidationReport{File: config.MapFile, Entries: []EntryReport{}}, err
+	}
+	rrmap, report, err := loadRequestResponseMap(config, reqJS, resJS)
+	if err != nil {
+		return nil, report, err
+	}
+
+	exchanges := []Exchange{}
+	for body, candidates := range rrmap.bodies {
+	candidates:
+		for _, candidate := range candidates {
+			exchange := Exchange{Index: candidate.index, Query: normalizeQuery(candidate.query.params, nil), Headers: http.Header{},
+				Request: body, Response: candidate.response, ContentType: candidate.contentType}
+			rnd := rand.New(rand.NewSource(int64(candidate.index)))
+			for name, predicate := range candidate.headers {
+				switch {
+				case predicate.Present != nil && !*predicate.Present:
+				case predicate.Equals != nil:
+					exchange.Headers.Set(name, *predicate.Equals)
+				case predicate.regex != nil:
+					value, err := headerValue(predicate.regex, rnd)
+					if err != nil {
+						log.Println("Entry " + strconv.Itoa(candidate.index) + " not exported, header " + name + ": " + err.Error())
+						continue candidates
+					}
+					exchange.Headers.Set(name, value)
+				default:
+					exchange.Headers.Set(name, "1")
+				}
+			}
+			exchanges = append(exchanges, exchange)
+		}
+	}
+	sort.Slice(exchanges, func(i, j int) bool { return exchanges[i].Index < exchanges[j].Index })
+	return exchanges, report, nil
+}
+
+// some header value matching that regex, as the response generator does with patterns
+func headerValue(regex *regexp.Regexp, rnd *rand.Rand) (string, error) {
+
+	re, err := syntax.Parse(regex.String(), syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+	for attempt := 0; attempt < 10; attempt++ {
+		value, err := fromRegexp(re.Simplify(), rnd)
+		if err != nil {
+			return "", err
+		}
+		if regex.MatchString(value) && strings.IndexFunc(value, unicode.IsControl) < 0 {
+			return value, nil
+		}
+	}
+	return "", errors.New("Unable to find a value matching " + regex.String())
+}
+
+// url of that exchange, its query after '?'
+func (e Exchange) url(baseURL string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(baseURL, "?"), "/") + e.Path + "?" + e.Query
+}
+
+// POST when there is a body to send
+func (e Exchange) method() string {
+	if len(e.Request) > 0 {
+		return http.MethodPost
+	}
+	return http.MethodGet
+}
+
+// ordered header names
+func (e Exchange) headerNames() []string {
+	names := make([]string, 0, len(e.Headers))
+	for name := range e.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Shell script with a curl command per exchange
+func ExportCurl(exchanges []Exchange, baseURL string) []byte {
+
+	var script bytes.Buffer
+	script.WriteString("#!/bin/sh\n")
+	for _, exchange := range exchanges {
+		script.WriteString("\n# entry " + strconv.Itoa(exchange.Index) + "\n")
+		script.WriteString("curl -s -X " + exchange.method() + " " + shellQuote(exchange.url(baseURL)))
+		for _, name := range exchange.headerNames() {
+			script.WriteString(" \\\n  -H " + shellQuote(name+": "+exchange.Headers.Get(name)))
+		}
+		if len(exchange.Request) > 0 {
+			script.WriteString(" \\\n  -H " + shellQuote("Content-Type: "+JsonContentType))
+			script.WriteString(" \\\n  --data-binary " + shellQuote(exchange.Request))
+		}
+		script.WriteString("\n")
+	}
+	return script.Bytes()
+}
+
+// single quoted for any POSIX shell
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+// HAR 1.2 capture with every exchange, to be imported by other tools
+func ExportHAR(exchanges []Exchange, baseURL string) ([]byte, error) {
+
+	type nameValue struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	type postData struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+	}
+	type content struct {
+		Size     int    `json:"size"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+		Encoding string `json:"encoding,omitempty"`
+	}
+	type request struct {
+		Method      string      `json:"method"`
+		URL         string      `json:"url"`
+		HTTPVersion string      `json:"httpVersion"`
+		Cookies     []nameValue `json:"cookies"`
+		Headers     []nameValue `json:"headers"`
+		QueryString []nameValue `json:"queryString"`
+		PostData    *postData   `json:"postData,omitempty"`
+		HeadersSize int         `json:"headersSize"`
+		BodySize    int         `json:"bodySize"`
+	}
+	type response struct {
+		Status      int         `json:"status"`
+		StatusText  string      `json:"statusText"`
+		HTTPVersion string      `json:"httpVersion"`
+		Cookies     []nameValue `json:"cookies"`
+		Headers     []nameValue `json:"headers"`
+		Content     content     `json:"content"`
+		RedirectURL string      `json:"redirectURL"`
+		HeadersSize int         `json:"headersSize"`
+		BodySize    int         `json:"bodySize"`
+	}
+	type entry struct {
+		StartedDateTime string         `json:"startedDateTime"`
+		Time            int            `json:"time"`
+		Request         request        `json:"request"`
+		Response        response       `json:"response"`
+		Cache           struct{}       `json:"cache"`
+		Timings         map[string]int `json:"timings"`
+		Comment         string         `json:"comment"`
+	}
+
+	started := time.Now().UTC().Format(time.RFC3339)
+	entries := []entry{}
+	for _, exchange := range exchanges {
+		req := request{Method: exchange.method(), URL: exchange.url(baseURL), HTTPVersion: "HTTP/1.1", Cookies: []nameValue{},
+			Headers: []nameValue{}, QueryString: []nameValue{}, HeadersSize: -1, BodySize: len(exchange.Request)}
+		for _, name := range exchange.headerNames() {
+			req.Headers = append(req.Headers, nameValue{name, exchange.Headers.Get(name)})
+		}
+		params, _ := url.ParseQuery(exchange.Query)
+		for _, name := range sortedValueNames(params) {
+			for _, value := range params[name] {
+				req.QueryString = append(req.QueryString, nameValue{name, value})
+			}
+		}
+		if len(exchange.Request) > 0 {
+			req.Headers = append(req.Headers, nameValue{"Content-Type", JsonContentType})
+			req.PostData = &postData{MimeType: JsonContentType, Text: exchange.Request}
+		}
+
+		res := response{Status: http.StatusOK, StatusText: "OK", HTTPVersion: "HTTP/1.1", Cookies: []nameValue{},
+			Headers:     []nameValue{{"Content-Type", exchange.ContentType}},
+			Content:     content{Size: len(exchange.Response), MimeType: exchange.ContentType, Text: exchange.Response},
+			HeadersSize: -1, BodySize: len(exchange.Response)}
+		if !utf8.ValidString(exchange.Response) {
+			res.Content.Text = base64.StdEncoding.EncodeToString([]byte(exchange.Response))
+			res.Content.Encoding = "base64"
+		}
+
+		entries = append(entries, entry{StartedDateTime: started, Request: req, Response: res,
+			Timings: map[string]int{"send": 0, "wait": 0, "receive": 0}, Comment: "entry " + strconv.Itoa(exchange.Index)})
+	}
+
+	har := map[string]interface{}{"log": map[string]interface{}{
+		"version": "1.2",
+		"creator": map[string]string{"name": "JsonMock", "version": "1"},
+		"entries": entries,
+	}}
+	var buffer bytes.Buffer
+	enc := json.NewEncoder(&buffer)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(har); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Go table-driven test replaying every exchange against a base URL, by default that one
+func ExportGoTest(exchanges []Exchange, baseURL string) ([]byte, error) {
+
+	var source bytes.Buffer
+	source.WriteString(`// Generated by JsonMock export: replays every entry of the map against -baseURL
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+var baseURL = flag.String("baseURL", ` + strconv.Quote(baseURL) + `, "Base URL of the mock under test, every query after '?'.")
+
+func TestReplay(t *testing.T) {
+
+	cases := []struct {
+		name        string
+		path        string
+		query       string
+		headers     map[string]string
+		request     string
+		contentType string
+		response    string
+	}{
+`)
+	for _, exchange := range exchanges {
+		source.WriteString("{name: " + strconv.Quote("entry "+strconv.Itoa(exchange.Index)) + ", path: " + strconv.Quote(exchange.Path) +
+			", query: " + strconv.Quote(exchange.Query) + ", headers: map[string]string{")
+		for _, name := range exchange.headerNames() {
+			source.WriteString(strconv.Quote(name) + ": " + strconv.Quote(exchange.Headers.Get(name)) + ", ")
+		}
+		source.WriteString("}, request: " + strconv.Quote(exchange.Request) + ", contentType: " + strconv.Quote(exchange.ContentType) +
+			", response: " + strconv.Quote(exchange.Response) + "},\n")
+	}
+	source.WriteString(`}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			method := http.MethodGet
+			if len(c.request) > 0 {
+				method = http.MethodPost
+			}
+			url := strings.TrimSuffix(strings.TrimSuffix(*baseURL, "?"), "/") + c.path + "?" + c.query
+			req, err := http.NewRequest(method, url, strings.NewReader(c.request))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, value := range c.headers {
+				req.Header.Set(name, value)
+			}
+			if len(c.request) > 0 {
+				req.Header.Set("Content-Type", "` + JsonContentType + `")
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, err := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != http.StatusOK || string(content) != c.response {
+				t.Errorf("%s: got %d %q, expected 200 %q", url, res.StatusCode, content, c.response)
+			}
+			if contentType := res.Header.Get("Content-Type"); contentType != c.contentType {
+				t.Errorf("%s: got Content-Type %s, expected %s", url, contentType, c.contentType)
+			}
+		})
+	}
+}
+`)
+	return format.Source(source.Bytes())
+}
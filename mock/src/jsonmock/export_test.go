@@ -0,0 +1,102 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+
+	text := "it's"
+	config := Config{Entries: []Entry{
+		{Query: "id=1&b=2", Res: map[string]interface{}{"id": 1}},
+		{Req: json.RawMessage(`{ "name": "o'neil" }`), Headers: map[string]*HeaderPredicate{"X-Version": {Equals: &text}}, Res: map[string]interface{}{"seat": "a"}},
+		{Query: "ping", ResponseBody: ResponseBody{Body: &text}},
+	}}
+	exchanges, report, err := Exchanges(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exchanges) != 3 || report.Valid != 3 {
+		t.Fatalf("got %d exchanges, expected 3", len(exchanges))
+	}
+	if first := exchanges[0]; first.Index != 0 || first.Query != "b=2&id=1" || first.Response != `{"id":1}` {
+		t.Errorf("unexpected exchange %+v", first)
+	}
+	if second := exchanges[1]; second.Request != `{"name":"o'neil"}` || second.Headers.Get("X-Version") != text {
+		t.Errorf("unexpected exchange %+v", second)
+	}
+
+	curl := string(ExportCurl(exchanges, "http://localhost/testingEnd?"))
+	for _, expected := range []string{
+		`curl -s -X GET 'http://localhost/testingEnd?b=2&id=1'`,
+		`-H 'X-Version: it'\''s'`,
+		`--data-binary '{"name":"o'\''neil"}'`,
+	} {
+		if !strings.Contains(curl, expected) {
+			t.Errorf("%s not found at\n%s", expected, curl)
+		}
+	}
+
+	// other tools get back the very same entries
+	har, err := ExportHAR(exchanges, "http://localhost/testingEnd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := ImportHAR(har, "", nil, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported.Entries) != 2 || len(imported.Dropped) != 1 || imported.Entries[0].Query != "b=2&id=1" {
+		t.Errorf("unexpected HAR import %+v", imported)
+	}
+
+	source, err := ExportGoTest(exchanges, "http://localhost/testingEnd?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(source), "func TestReplay(t *testing.T)") || !strings.Contains(string(source), `"X-Version": "it's"`) {
+		t.Errorf("unexpected Go test\n%s", source)
+	}
+}
+
+func TestExportReplay(t *testing.T) {
+
+	entries := append(testEntries(),
+		Entry{Query: "id=5", Headers: map[string]*HeaderPredicate{"X-Seat": {Regex: "^seat-[0-9]{3}$"}, "X-Debug": {Present: new(bool)}}, Res: map[string]interface{}{"id": 5}},
+		Entry{Query: "id=6", Headers: map[string]*HeaderPredicate{"X-Seat": {Regex: `^[^\s\S]$`}}, Res: map[string]interface{}{"id": 6}})
+	exchanges, _, err := Exchanges(Config{Entries: entries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exchanges) != len(entries)-1 || exchanges[len(exchanges)-1].Index != 4 {
+		t.Fatalf("got %d exchanges %+v, expected every entry but the one nothing matches", len(exchanges), exchanges)
+	}
+
+	// every exported request answered by its very entry
+	server, err := NewTestServer(Config{Entries: entries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	for _, exchange := range exchanges {
+		req, err := http.NewRequest(exchange.method(), exchange.url(server.URL), strings.NewReader(exchange.Request))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header = exchange.Headers
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		record := server.Mock.Journal()[0]
+		if res.StatusCode != http.StatusOK || string(content) != exchange.Response || record.Entry == nil || *record.Entry != exchange.Index {
+			t.Errorf("entry %d: got %d %q from %+v, expected its own response", exchange.Index, res.StatusCode, content, record)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package jsonmock
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// fixed json answer of last resort, valid against the response Json Schema
+func loadDefaultResponse(defaultFile string, resJsonSchema *gojsonschema.Schema) (*QueryResponse, error) {
+
+	content, err := ioutil.ReadFile(defaultFile)
+	if err != nil {
+		log.Println(err)
+		return nil, errors.New("Unable to read Default Response File.")
+	}
+	response, err := compactJson(content)
+	if err != nil {
+		log.Println(err)
+		return nil, errors.New("Unable to process Default Response File.")
+	}
+	if errs := schemaErrors(resJsonSchema, response); len(errs) > 0 {
+		log.Println("Default Response File is not valid. See errors: ")
+		for _, desc := range errs {
+			log.Printf("- %s\n", desc)
+		}
+		return nil, errors.New("Invalid Default Response File")
+	}
+	fallback := newQueryResponse(response, JsonContentType)
+	fallback.key = syntheticKey + MatchFallback
+	return &fallback, nil
+}
+
+// real server answering whatever the mock cannot, path and query appended to its own ones
+func newProxy(proxyURL string) (*httputil.ReverseProxy, error) {
+
+	target, err := url.Parse(proxyURL)
+	if err != nil || len(target.Scheme) == 0 || len(target.Host) == 0 {
+		return nil, errors.New("Unable to proxy to " + proxyURL + ": absolute url expected")
+	}
+	return httputil.NewSingleHostReverseProxy(target), nil
+}
@@ -0,0 +1,554 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/url"
+	"path/filepath"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// When fake responses are generated from the response Json Schema
+const (
+	GenerateOff    = "off"
+	GenerateMiss   = "miss"
+	GenerateAlways = "always"
+)
+
+// limits to keep generated documents small
+const generatorMaxDepth = 8
+const generatorMaxItems = 3
+const generatorMaxRepeat = 5
+const generatorAttempts = 10
+
+// Random but schema-valid responses
+type ResponseGenerator struct {
+	root      string
+	docs      map[string]interface{}
+	docsMutex sync.Mutex
+	schema    *gojsonschema.Schema
+	seed      int64
+	debug     string
+}
+
+// schema being generated and the document it belongs to, to resolve relative $ref
+type generatorContext struct {
+	rnd   *rand.Rand
+	doc   string
+	depth int
+}
+
+// load the raw response Json Schema, and the ones it references, next to its compiled version
+func newResponseGenerator(responseJsonSchemaFile string, schema *gojsonschema.Schema, seed int64, debugParameter string) (*ResponseGenerator, error) {
+
+	root, err := filepath.Abs(responseJsonSchemaFile)
+	if err != nil {
+		return nil, err
+	}
+	generator := &ResponseGenerator{root: root, docs: make(map[string]interface{}), schema: schema, seed: seed, debug: debugParameter}
+	if _, err = generator.document(root); err != nil {
+		return nil, err
+	}
+	return generator, nil
+}
+
+// generate a response for that request; the same request gets the same response for the same seed
+func (g *ResponseGenerator) Generate(body string, params url.Values) (string, error) {
+
+	hash := fnv.New64a()
+	hash.Write([]byte(normalizeQuery(params, map[string]bool{g.debug: true})))
+	hash.Write([]byte(body))
+	rnd := rand.New(rand.NewSource(g.seed ^ int64(hash.Sum64())))
+
+	root, err := g.document(g.root)
+	if err != nil {
+		return "", err
+	}
+
+	var lastErrors []string
+	for attempt := 0; attempt < generatorAttempts; attempt++ {
+		value, err := g.generate(root, &generatorContext{rnd: rnd, doc: g.root})
+		if err != nil {
+			return "", err
+		}
+		response, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		lastErrors = schemaErrors(g.schema, string(response))
+		if len(lastErrors) == 0 {
+			return string(response), nil
+		}
+	}
+	return "", errors.New("Unable to generate a valid response: " + strings.Join(lastErrors, "; "))
+}
+
+// raw json document of a schema file, cached
+func (g *ResponseGenerator) document(path string) (interface{}, error) {
+
+	g.docsMutex.Lock()
+	defer g.docsMutex.Unlock()
+
+	if doc, found := g.docs[path]; found {
+		return doc, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	dec := json.NewDecoder(strings.NewReader(string(content)))
+	dec.UseNumber()
+	if err = dec.Decode(&doc); err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+	g.docs[path] = doc
+	return doc, nil
+}
+
+// follow a local or cross-file $ref, returning the referenced schema and its document
+func (g *ResponseGenerator) resolve(ref string, doc string) (map[string]interface{}, string, error) {
+
+	parts := strings.SplitN(ref, "#", 2)
+	if len(parts[0]) > 0 {
+		file := strings.TrimPrefix(parts[0], "file://")
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(doc), filepath.FromSlash(file))
+		}
+		doc = file
+	}
+	node, err := g.document(doc)
+	if err != nil {
+		return nil, doc, err
+	}
+
+	if len(parts) > 1 && len(parts[1]) > 0 {
+		for _, token := range strings.Split(strings.TrimPrefix(parts[1], "/"), "/") {
+			token, _ = url.PathUnescape(token)
+			token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+			switch current := node.(type) {
+			case map[string]interface{}:
+				node = current[token]
+			case []interface{}:
+				i, err := strconv.Atoi(token)
+				if err != nil || i < 0 || i >= len(current) {
+					return nil, doc, errors.New("Unable to resolve $ref " + ref)
+				}
+				node = current[i]
+			default:
+				return nil, doc, errors.New("Unable to resolve $ref " + ref)
+			}
+		}
+	}
+
+	schema, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, doc, errors.New("Unable to resolve $ref " + ref)
+	}
+	return schema, doc, nil
+}
+
+// value for a schema node
+func (g *ResponseGenerator) generate(node interface{}, ctx *generatorContext) (interface{}, error) {
+
+	schema, ok := node.(map[string]interface{})
+	if !ok {
+		// boolean or empty schemas accept anything
+		return nil, nil
+	}
+
+	if ref, found := schema["$ref"].(string); found {
+		resolved, doc, err := g.resolve(ref, ctx.doc)
+		if err != nil {
+			return nil, err
+		}
+		return g.generate(resolved, &generatorContext{rnd: ctx.rnd, doc: doc, depth: ctx.depth})
+	}
+
+	if value, found := schema["default"]; found {
+		return value, nil
+	}
+	if value, found := schema["const"]; found {
+		return value, nil
+	}
+	if enum, found := schema["enum"].([]interface{}); found && len(enum) > 0 {
+		return enum[ctx.rnd.Intn(len(enum))], nil
+	}
+
+	if allOf, found := schema["allOf"].([]interface{}); found {
+		return g.generate(mergeSchemas(schema, allOf), ctx)
+	}
+	for _, keyword := range []string{"oneOf", "anyOf"} {
+		if options, found := schema[keyword].([]interface{}); found && len(options) > 0 {
+			return g.generate(options[ctx.rnd.Intn(len(options))], ctx)
+		}
+	}
+
+	switch schemaType(schema) {
+	case "object":
+		return g.object(schema, ctx)
+	case "array":
+		return g.array(schema, ctx)
+	case "string":
+		return generateString(schema, ctx.rnd)
+	case "integer":
+		return generateInteger(schema, ctx.rnd), nil
+	case "number":
+		return generateNumber(schema, ctx.rnd), nil
+	case "boolean":
+		return ctx.rnd.Intn(2) == 0, nil
+	case "null":
+		return nil, nil
+	}
+	return nil, nil
+}
+
+// every required property and, not too deep, some optional ones
+func (g *ResponseGenerator) object(schema map[string]interface{}, ctx *generatorContext) (interface{}, error) {
+
+	result := make(map[string]interface{})
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	required := make(map[string]bool)
+	if list, found := schema["required"].([]interface{}); found {
+		for _, name := range list {
+			if s, ok := name.(string); ok {
+				required[s] = true
+			}
+		}
+	}
+
+	// ordered names so the same seed produces the same document
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	child := &generatorContext{rnd: ctx.rnd, doc: ctx.doc, depth: ctx.depth + 1}
+	for _, name := range names {
+		if !required[name] && (ctx.depth >= generatorMaxDepth || ctx.rnd.Intn(2) == 0) {
+			continue
+		}
+		value, err := g.generate(properties[name], child)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = value
+	}
+
+	// required but not described
+	for name := range required {
+		if _, found := result[name]; !found {
+			result[name] = "string"
+		}
+	}
+	return result, nil
+}
+
+// between minItems and maxItems items
+func (g *ResponseGenerator) array(schema map[string]interface{}, ctx *generatorContext) (interface{}, error) {
+
+	min := intKeyword(schema, "minItems", 0)
+	max := intKeyword(schema, "maxItems", min+generatorMaxItems)
+	if ctx.depth >= generatorMaxDepth {
+		max = min
+	}
+	count := min
+	if max > min {
+		count += ctx.rnd.Intn(max - min + 1)
+	}
+
+	result := make([]interface{}, 0, count)
+	child := &generatorContext{rnd: ctx.rnd, doc: ctx.doc, depth: ctx.depth + 1}
+	for i := 0; i < count; i++ {
+		items := schema["items"]
+		if tuple, found := items.([]interface{}); found {
+			if i >= len(tuple) {
+				break
+			}
+			items = tuple[i]
+		}
+		value, err := g.generate(items, child)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+	}
+	return result, nil
+}
+
+// allOf subschemas merged into a single one: properties, required and the first type found
+func mergeSchemas(schema map[string]interface{}, allOf []interface{}) map[string]interface{} {
+
+	merged := make(map[string]interface{})
+	for k, v := range schema {
+		if k != "allOf" {
+			merged[k] = v
+		}
+	}
+	properties := make(map[string]interface{})
+	if own, found := schema["properties"].(map[string]interface{}); found {
+		for k, v := range own {
+			properties[k] = v
+		}
+	}
+	required, _ := schema["required"].([]interface{})
+
+	for _, sub := range allOf {
+		subSchema, ok := sub.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, v := range subSchema {
+			switch k {
+			case "properties":
+				if subProperties, ok := v.(map[string]interface{}); ok {
+					for name, property := range subProperties {
+						properties[name] = property
+					}
+				}
+			case "required":
+				if list, ok := v.([]interface{}); ok {
+					required = append(required, list...)
+				}
+			default:
+				if _, found := merged[k]; !found {
+					merged[k] = v
+				}
+			}
+		}
+	}
+	if len(properties) > 0 {
+		merged["properties"] = properties
+	}
+	if len(required) > 0 {
+		merged["required"] = required
+	}
+	return merged
+}
+
+// declared type, the first non-null one when several, or guessed from other keywords
+func schemaType(schema map[string]interface{}) string {
+
+	switch t := schema["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		for _, option := range t {
+			if s, ok := option.(string); ok && s != "null" {
+				return s
+			}
+		}
+		return "null"
+	}
+	if _, found := schema["properties"]; found {
+		return "object"
+	}
+	if _, found := schema["items"]; found {
+		return "array"
+	}
+	return ""
+}
+
+// string from its pattern, its format or just its length limits
+func generateString(schema map[string]interface{}, rnd *rand.Rand) (string, error) {
+
+	if pattern, found := schema["pattern"].(string); found {
+		if re, err := syntax.Parse(pattern, syntax.Perl); err == nil {
+			value, err := fromRegexp(re.Simplify(), rnd)
+			if err != nil {
+				return "", errors.New("Unable to generate a string matching pattern " + pattern + ": " + err.Error())
+			}
+			return value, nil
+		}
+	}
+
+	switch schema["format"] {
+	case "date-time":
+		return "2017-0" + strconv.Itoa(1+rnd.Intn(9)) + "-1" + strconv.Itoa(rnd.Intn(10)) + "T12:00:00Z", nil
+	case "email":
+		return randomLetters(rnd, 6) + "@example.com", nil
+	case "uri", "url":
+		return "http://example.com/" + randomLetters(rnd, 6), nil
+	case "ipv4":
+		return "10.0." + strconv.Itoa(rnd.Intn(256)) + "." + strconv.Itoa(1+rnd.Intn(254)), nil
+	case "hostname":
+		return randomLetters(rnd, 6) + ".example.com", nil
+	}
+
+	min := intKeyword(schema, "minLength", 1)
+	max := intKeyword(schema, "maxLength", min+8)
+	length := min
+	if max > min {
+		length += rnd.Intn(max - min + 1)
+	}
+	return randomLetters(rnd, length), nil
+}
+
+// integer between minimum and maximum, multiple of multipleOf if any
+func generateInteger(schema map[string]interface{}, rnd *rand.Rand) int64 {
+
+	min, max := numberLimits(schema, 0, 1000)
+	lo := int64(math.Ceil(min))
+	hi := int64(math.Floor(max))
+	if exclusive, _ := schema["exclusiveMinimum"].(bool); exclusive && float64(lo) == min {
+		lo++
+	}
+	if exclusive, _ := schema["exclusiveMaximum"].(bool); exclusive && float64(hi) == max {
+		hi--
+	}
+	if hi < lo {
+		hi = lo
+	}
+	value := lo + rnd.Int63n(hi-lo+1)
+
+	if multiple := floatKeyword(schema, "multipleOf", 0); multiple >= 1 {
+		step := int64(multiple)
+		value = (value / step) * step
+		if value < lo {
+			value += step
+		}
+	}
+	return value
+}
+
+// number between minimum and maximum, rounded to cents as prices usually are
+func generateNumber(schema map[string]interface{}, rnd *rand.Rand) float64 {
+
+	min, max := numberLimits(schema, 0, 100)
+	value := min + rnd.Float64()*(max-min)
+	value = math.Round(value*100) / 100
+	if value < min {
+		value = min
+	}
+	if value > max {
+		value = max
+	}
+	if exclusive, _ := schema["exclusiveMinimum"].(bool); exclusive && value == min {
+		value = min + (max-min)/2
+	}
+	if exclusive, _ := schema["exclusiveMaximum"].(bool); exclusive && value == max {
+		value = min + (max-min)/2
+	}
+	return value
+}
+
+// minimum and maximum, default ones around the given limit
+func numberLimits(schema map[string]interface{}, lo float64, hi float64) (float64, float64) {
+
+	_, hasMin := schema["minimum"]
+	_, hasMax := schema["maximum"]
+	min := floatKeyword(schema, "minimum", lo)
+	max := floatKeyword(schema, "maximum", hi)
+	if hasMin && !hasMax {
+		max = min + (hi - lo)
+	}
+	if hasMax && !hasMin {
+		min = max - (hi - lo)
+	}
+	return min, max
+}
+
+// numeric keyword as float
+func floatKeyword(schema map[string]interface{}, keyword string, fallback float64) float64 {
+	if number, ok := schema[keyword].(json.Number); ok {
+		if value, err := number.Float64(); err == nil {
+			return value
+		}
+	}
+	return fallback
+}
+
+// numeric keyword as int
+func intKeyword(schema map[string]interface{}, keyword string, fallback int) int {
+	return int(floatKeyword(schema, keyword, float64(fallback)))
+}
+
+// lower case letters
+func randomLetters(rnd *rand.Rand, length int) string {
+	letters := make([]byte, length)
+	for i := range letters {
+		letters[i] = byte('a' + rnd.Intn(26))
+	}
+	return string(letters)
+}
+
+// string matching a parsed regular expression; an error when nothing can match it, as an empty class [^\s\S]
+func fromRegexp(re *syntax.Regexp, rnd *rand.Rand) (string, error) {
+
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return "", errors.New("nothing matches " + re.String())
+	case syntax.OpLiteral:
+		return string(re.Rune), nil
+	case syntax.OpCharClass:
+		if len(re.Rune) == 0 {
+			return "", errors.New("no character in class " + re.String())
+		}
+		// pairs of ranges, printable ASCII preferred
+		for attempt := 0; attempt < 10; attempt++ {
+			i := rnd.Intn(len(re.Rune)/2) * 2
+			lo, hi := re.Rune[i], re.Rune[i+1]
+			if hi > 0x7e && lo <= 0x7e {
+				hi = 0x7e
+			}
+			r := lo + rune(rnd.Intn(int(hi-lo)+1))
+			if r >= 0x20 && r <= 0x7e {
+				return string(r), nil
+			}
+		}
+		return string(re.Rune[0]), nil
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return string(rune('a' + rnd.Intn(26))), nil
+	case syntax.OpCapture:
+		return fromRegexp(re.Sub[0], rnd)
+	case syntax.OpConcat:
+		result := ""
+		for _, sub := range re.Sub {
+			value, err := fromRegexp(sub, rnd)
+			if err != nil {
+				return "", err
+			}
+			result += value
+		}
+		return result, nil
+	case syntax.OpAlternate:
+		return fromRegexp(re.Sub[rnd.Intn(len(re.Sub))], rnd)
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		min, max := 0, generatorMaxRepeat
+		switch re.Op {
+		case syntax.OpPlus:
+			min = 1
+		case syntax.OpQuest:
+			max = 1
+		case syntax.OpRepeat:
+			min, max = re.Min, re.Max
+			if max < 0 {
+				max = min + generatorMaxRepeat
+			}
+		}
+		count := min + rnd.Intn(max-min+1)
+		result := ""
+		for i := 0; i < count; i++ {
+			value, err := fromRegexp(re.Sub[0], rnd)
+			if err != nil {
+				return "", err
+			}
+			result += value
+		}
+		return result, nil
+	}
+	// anchors, word boundaries and empty matches
+	return "", nil
+}
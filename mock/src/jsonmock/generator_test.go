@@ -0,0 +1,119 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// generator of that response Json Schema, written to a temporary file removed by the returned func
+func testGenerator(t *testing.T, schema string, seed int64) (*ResponseGenerator, func()) {
+
+	dir, err := ioutil.TempDir("", "generator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "response.json")
+	if err = ioutil.WriteFile(file, []byte(schema), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	_, resJS, err := loadJsonSchemas(Config{ResponseSchemaFile: file}.withDefaults())
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	generator, err := newResponseGenerator(file, resJS, seed, DefaultDebugParameter)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return generator, func() { os.RemoveAll(dir) }
+}
+
+func TestGeneratorKeywords(t *testing.T) {
+
+	cases := []struct {
+		name   string
+		schema string
+		check  func(value interface{}) bool
+	}{
+		{"enum", `{"type": "object", "required": ["cur"], "properties": {"cur": {"enum": ["USD", "EUR"]}}}`, nil},
+		{"default", `{"type": "object", "required": ["id"], "properties": {"id": {"type": "string", "default": "fixed"}}}`,
+			func(value interface{}) bool { return value.(map[string]interface{})["id"] == "fixed" }},
+		{"minimum and maximum", `{"type": "integer", "minimum": 5, "maximum": 7}`, nil},
+		{"exclusive maximum", `{"type": "number", "minimum": 0.5, "maximum": 1.5, "exclusiveMaximum": true}`, nil},
+		{"multipleOf", `{"type": "integer", "minimum": 10, "maximum": 100, "multipleOf": 5}`, nil},
+		{"pattern", `{"type": "string", "pattern": "^[A-Z]{3}-[0-9]{2,4}$"}`, nil},
+		{"length", `{"type": "string", "minLength": 3, "maxLength": 5}`, nil},
+		{"required", `{"type": "object", "required": ["a", "b"], "properties": {"a": {"type": "integer"},
+			"b": {"type": "array", "minItems": 1, "maxItems": 2, "items": {"type": "string", "format": "email"}}, "c": {"type": "boolean"}}}`,
+			func(value interface{}) bool {
+				object := value.(map[string]interface{})
+				return object["a"] != nil && object["b"] != nil
+			}},
+	}
+	for _, c := range cases {
+		generator, remove := testGenerator(t, c.schema, 1)
+		root, err := generator.document(generator.root)
+		if err != nil {
+			remove()
+			t.Fatal(err)
+		}
+		// every single attempt valid, not just one out of the retries of Generate
+		for seed := int64(0); seed < 20; seed++ {
+			value, err := generator.generate(root, &generatorContext{rnd: rand.New(rand.NewSource(seed)), doc: generator.root})
+			if err != nil {
+				t.Errorf("%s: %v", c.name, err)
+				continue
+			}
+			content, _ := json.Marshal(value)
+			if errs := schemaErrors(generator.schema, string(content)); len(errs) > 0 {
+				t.Errorf("%s: %s not valid: %v", c.name, content, errs)
+			}
+			if c.check != nil && !c.check(value) {
+				t.Errorf("%s: unexpected %s", c.name, content)
+			}
+		}
+		remove()
+	}
+}
+
+func TestGeneratorSeed(t *testing.T) {
+
+	schema := `{"type": "object", "required": ["id", "price"], "properties": {"id": {"type": "string", "pattern": "^[a-f0-9]{8}$"},
+		"price": {"type": "number", "minimum": 0.1, "maximum": 9.9}, "tags": {"type": "array", "items": {"type": "string"}}}}`
+	first, removeFirst := testGenerator(t, schema, 42)
+	defer removeFirst()
+	second, removeSecond := testGenerator(t, schema, 42)
+	defer removeSecond()
+
+	params := url.Values{"id": []string{"1"}}
+	expected, err := first.Generate(`{"id":"1"}`, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, generator := range []*ResponseGenerator{first, second} {
+		if got, err := generator.Generate(`{"id":"1"}`, params); err != nil || got != expected {
+			t.Errorf("got %s (%v), expected the same %s for the same seed and request", got, err, expected)
+		}
+	}
+	if other, err := first.Generate(`{"id":"2"}`, nil); err != nil || other == expected {
+		t.Errorf("got %s (%v), expected another response for another request", other, err)
+	}
+}
+
+func TestGeneratorEmptyClass(t *testing.T) {
+
+	for _, pattern := range []string{`[^\\s\\S]`, `^a[^\\x00-\\x{10FFFF}]+$`} {
+		generator, remove := testGenerator(t, `{"type": "object", "required": ["id"], "properties": {"id": {"type": "string", "pattern": "`+pattern+`"}}}`, 1)
+		if _, err := generator.Generate("", nil); err == nil {
+			t.Errorf("%s: expected an error instead of a string matching nothing", pattern)
+		}
+		remove()
+	}
+}
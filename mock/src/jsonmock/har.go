@@ -0,0 +1,167 @@
+package jsonmock
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HAR entry left out of the import, and why
+type DroppedEntry struct {
+	Index   int      `json:"index"`
+	Method  string   `json:"method"`
+	URL     string   `json:"url"`
+	Reasons []string `json:"reasons"`
+}
+
+// Entries converted from a HAR capture, along with the ones left out
+type HARImport struct {
+	Entries []Entry
+	Dropped []DroppedEntry
+}
+
+// just the parts of a HAR capture the mock cares about
+type harLog struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method   string `json:"method"`
+				URL      string `json:"url"`
+				PostData *struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+			Response struct {
+				Status  int `json:"status"`
+				Content struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+					Encoding string `json:"encoding"`
+				} `json:"content"`
+			} `json:"response"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// Convert the HAR entries with json bodies matching that url pattern and methods, every one when empty,
+// into entries deduplicated by lookup key and valid against the Json Schemas of that configuration
+func ImportHAR(content []byte, urlPattern string, methods []string, config Config) (*HARImport, error) {
+
+	var har harLog
+	if err := json.Unmarshal(content, &har); err != nil {
+		return nil, err
+	}
+	pattern, err := regexp.Compile(urlPattern)
+	if err != nil {
+		return nil, err
+	}
+	allowed := make(map[string]bool)
+	for _, method := range methods {
+		if method = strings.ToUpper(strings.TrimSpace(method)); len(method) > 0 {
+			allowed[method] = true
+		}
+	}
+
+	result := &HARImport{Entries: []Entry{}, Dropped: []DroppedEntry{}}
+	candidates := []Entry{}
+	origins := []int{} // HAR index of every candidate
+	for index, harEntry := range har.Log.Entries {
+		request, response := harEntry.Request, harEntry.Response
+		dropped := DroppedEntry{Index: index, Method: request.Method, URL: request.URL}
+
+		parsed, err := url.Parse(request.URL)
+		switch {
+		case len(allowed) > 0 && !allowed[strings.ToUpper(request.Method)]:
+			dropped.Reasons = []string{"method filtered out"}
+		case !pattern.MatchString(request.URL):
+			dropped.Reasons = []string{"url filtered out"}
+		case err != nil:
+			dropped.Reasons = []string{"url: " + err.Error()}
+		case response.Status < 200 || response.Status > 299:
+			dropped.Reasons = []string{"status " + strconv.Itoa(response.Status)}
+		case !isJsonMimeType(response.Content.MimeType):
+			dropped.Reasons = []string{"response is not json but " + response.Content.MimeType}
+		case request.PostData != nil && len(request.PostData.Text) > 0 && !isJsonMimeType(request.PostData.MimeType):
+			dropped.Reasons = []string{"request is not json but " + request.PostData.MimeType}
+		}
+		if len(dropped.Reasons) > 0 {
+			result.Dropped = append(result.Dropped, dropped)
+			continue
+		}
+
+		res := response.Content.Text
+		if response.Content.Encoding == "base64" {
+			decoded, err := base64.StdEncoding.DecodeString(res)
+			if err != nil {
+				dropped.Reasons = []string{"response: " + err.Error()}
+				result.Dropped = append(result.Dropped, dropped)
+				continue
+			}
+			res = string(decoded)
+		}
+
+		entry := Entry{Query: parsed.Query().Encode(), Res: json.RawMessage(res)}
+		if request.PostData != nil && len(request.PostData.Text) > 0 {
+			entry.Req = json.RawMessage(request.PostData.Text)
+		}
+		if !json.Valid([]byte(res)) || (entry.Req != nil && !json.Valid([]byte(request.PostData.Text))) {
+			dropped.Reasons = []string{"body is not valid json"}
+			result.Dropped = append(result.Dropped, dropped)
+			continue
+		}
+		candidates = append(candidates, entry)
+		origins = append(origins, index)
+	}
+
+	// the mock itself tells duplicated and invalid entries apart
+	config.MapFile = ""
+	config.Entries = candidates
+	reqJS, resJS, err := loadJsonSchemas(config.withDefaults())
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return result, nil
+	}
+	_, report, err := loadRequestResponseMap(config.withDefaults(), reqJS, resJS)
+	if report.Total != len(candidates) {
+		if err == nil {
+			err = errors.New("Unable to validate HAR entries")
+		}
+		return nil, err
+	}
+	owners := make(map[string]int) // HAR index of the entry kept for every key
+	for i, entryReport := range report.Entries {
+		if entryReport.Status == EntryValid {
+			owners[entryReport.Key] = origins[i]
+			result.Entries = append(result.Entries, candidates[i])
+		}
+	}
+	for i, entryReport := range report.Entries {
+		harEntry := har.Log.Entries[origins[i]]
+		dropped := DroppedEntry{Index: origins[i], Method: harEntry.Request.Method, URL: harEntry.Request.URL}
+		switch entryReport.Status {
+		case EntryValid:
+			continue
+		case EntryDuplicated:
+			dropped.Reasons = []string{"same lookup key as entry " + strconv.Itoa(owners[entryReport.Key])}
+		default:
+			dropped.Reasons = entryReport.Errors
+		}
+		result.Dropped = append(result.Dropped, dropped)
+	}
+	sort.Slice(result.Dropped, func(i, j int) bool { return result.Dropped[i].Index < result.Dropped[j].Index })
+	return result, nil
+}
+
+// application/json or any +json media type, parameters aside
+func isJsonMimeType(mimeType string) bool {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+	return mimeType == JsonContentType || strings.HasSuffix(mimeType, "+json")
+}
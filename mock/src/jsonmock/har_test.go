@@ -0,0 +1,74 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testHAR = `{ "log": { "entries": [
+  { "request": { "method": "POST", "url": "http://ssp.test/bid?v=2&x=1",
+                 "postData": { "mimeType": "application/json", "text": "{\"id\": \"a\"}" } },
+    "response": { "status": 200, "content": { "mimeType": "application/json; charset=utf-8", "text": "{\"seat\": \"s1\"}" } } },
+  { "request": { "method": "POST", "url": "http://ssp.test/bid?x=1&v=2",
+                 "postData": { "mimeType": "application/json", "text": "{ \"id\" : \"a\" }" } },
+    "response": { "status": 200, "content": { "mimeType": "application/json", "text": "{\"seat\": \"s2\"}" } } },
+  { "request": { "method": "GET", "url": "http://ssp.test/status" },
+    "response": { "status": 200, "content": { "mimeType": "application/json", "text": "eyJvayI6IHRydWV9", "encoding": "base64" } } },
+  { "request": { "method": "GET", "url": "http://cdn.test/app.js" },
+    "response": { "status": 200, "content": { "mimeType": "application/javascript", "text": "" } } },
+  { "request": { "method": "DELETE", "url": "http://ssp.test/bid" },
+    "response": { "status": 204, "content": { "mimeType": "application/json", "text": "" } } },
+  { "request": { "method": "GET", "url": "http://ssp.test/bid?x=2" },
+    "response": { "status": 404, "content": { "mimeType": "application/json", "text": "{}" } } },
+  { "request": { "method": "GET", "url": "http://ssp.test/bid?x=3" },
+    "response": { "status": 200, "content": { "mimeType": "application/json", "text": "{\"seat\": 3}" } } }
+] } }`
+
+func TestImportHAR(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := Config{ResponseSchemaFile: filepath.Join(dir, "response.json")}
+	schema := `{"$schema": "` + JsonSchemaDraft + `", "properties": {"seat": {"type": "string"}}}`
+	if err = ioutil.WriteFile(config.ResponseSchemaFile, []byte(schema), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := ImportHAR([]byte(testHAR), `^http://ssp\.test/`, []string{"get", "POST"}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(imported.Entries) != 2 {
+		t.Fatalf("got %d entries, expected 2: %+v", len(imported.Entries), imported.Entries)
+	}
+	if first := imported.Entries[0]; first.Query != "v=2&x=1" || first.Req == nil {
+		t.Errorf("unexpected entry %+v", first)
+	}
+	if res, _ := imported.Entries[1].Res.(json.RawMessage); string(res) != `{"ok": true}` {
+		t.Errorf("got response %s, expected the base64 decoded one", res)
+	}
+
+	expected := map[int]string{
+		1: "same lookup key as entry 0",
+		3: "url filtered out",
+		4: "method filtered out",
+		5: "status 404",
+	}
+	if len(imported.Dropped) != len(expected)+1 {
+		t.Fatalf("got %d dropped entries, expected %d: %+v", len(imported.Dropped), len(expected)+1, imported.Dropped)
+	}
+	for _, dropped := range imported.Dropped {
+		if reason, found := expected[dropped.Index]; found && dropped.Reasons[0] != reason {
+			t.Errorf("entry %d: got %v, expected %s", dropped.Index, dropped.Reasons, reason)
+		} else if !found && dropped.Index != 6 {
+			t.Errorf("entry %d unexpectedly dropped: %v", dropped.Index, dropped.Reasons)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Predicate on a single request header: exact value, regular expression or just present/absent
+type HeaderPredicate struct {
+	Equals  *string `json:"equals,omitempty"`
+	Regex   string  `json:"regex,omitempty"`
+	Present *bool   `json:"present,omitempty"`
+	regex   *regexp.Regexp
+}
+
+// Header predicates of an entry by canonical header name, all of them must hold
+type HeaderMatcher map[string]*HeaderPredicate
+
+// a bare string means an exact value
+func (p *HeaderPredicate) UnmarshalJSON(data []byte) error {
+
+	var exact string
+	if json.Unmarshal(data, &exact) == nil {
+		p.Equals = &exact
+		return nil
+	}
+
+	type plain HeaderPredicate
+	return json.Unmarshal(data, (*plain)(p))
+}
+
+// compile regular expressions and check out contradictory predicates
+func newHeaderMatcher(headers map[string]*HeaderPredicate) (HeaderMatcher, error) {
+
+	matcher := make(HeaderMatcher)
+	for name, predicate := range headers {
+		if predicate == nil {
+			return nil, errors.New("empty predicate for header " + name)
+		}
+		if predicate.Present != nil && !*predicate.Present && (predicate.Equals != nil || len(predicate.Regex) > 0) {
+			return nil, errors.New("header " + name + " cannot be absent and have a value")
+		}
+		if len(predicate.Regex) > 0 {
+			compiled, err := regexp.Compile(predicate.Regex)
+			if err != nil {
+				return nil, errors.New("header " + name + ": " + err.Error())
+			}
+			predicate.regex = compiled
+		}
+		matcher[http.CanonicalHeaderKey(name)] = predicate
+	}
+	return matcher, nil
+}
+
+// check out the headers of a request
+func (h HeaderMatcher) Match(headers http.Header) bool {
+
+	for name, predicate := range h {
+		values, found := headers[name]
+		if predicate.Present != nil && *predicate.Present != found {
+			return false
+		}
+		if predicate.Equals != nil && !contains(values, *predicate.Equals) {
+			return false
+		}
+		if predicate.regex != nil && !matchAny(values, predicate.regex) {
+			return false
+		}
+	}
+	return true
+}
+
+// why those headers don't match, nothing when they do
+func (h HeaderMatcher) diff(headers http.Header) []string {
+
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	differences := []string{}
+	for _, name := range names {
+		predicate := HeaderMatcher{name: h[name]}
+		if predicate.Match(headers) {
+			continue
+		}
+		got := strings.Join(headers[name], ",")
+		if len(headers[name]) == 0 {
+			got = "nothing"
+		}
+		differences = append(differences, "header "+predicate.String()+": got "+got)
+	}
+	return differences
+}
+
+// canonical representation, used as part of the lookup key
+func (h HeaderMatcher) String() string {
+
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	predicates := []string{}
+	for _, name := range names {
+		predicate := h[name]
+		if predicate.Present != nil {
+			if *predicate.Present {
+				predicates = append(predicates, name)
+			} else {
+				predicates = append(predicates, "!"+name)
+			}
+		}
+		if predicate.Equals != nil {
+			predicates = append(predicates, name+"="+*predicate.Equals)
+		}
+		if len(predicate.Regex) > 0 {
+			predicates = append(predicates, name+"~"+predicate.Regex)
+		}
+	}
+	return strings.Join(predicates, ";")
+}
+
+// any value exactly equal
+func contains(values []string, expected string) bool {
+	for _, value := range values {
+		if value == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// any value matching
+func matchAny(values []string, regex *regexp.Regexp) bool {
+	for _, value := range values {
+		if regex.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
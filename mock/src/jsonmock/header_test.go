@@ -0,0 +1,57 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestHeaderPredicates(t *testing.T) {
+
+	var entries []Entry
+	if err := json.Unmarshal([]byte(`[
+		{"query": "equals", "headers": {"x-openrtb-version": "2.5"}, "res": {"id": "equals"}},
+		{"query": "object", "headers": {"X-OPENRTB-VERSION": {"equals": "2.6"}}, "res": {"id": "object"}},
+		{"query": "absent", "headers": {"x-debug": {"present": false}}, "res": {"id": "absent"}},
+		{"query": "present", "headers": {"X-Debug": {"present": true}}, "res": {"id": "present"}},
+		{"query": "regex", "headers": {"user-agent": {"regex": "^Smaato/[0-9]+$"}}, "res": {"id": "regex"}}
+	]`), &entries); err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewTestServer(Config{Entries: entries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	cases := []struct {
+		query   string
+		headers map[string]string
+		found   bool
+	}{
+		{"equals", map[string]string{"X-Openrtb-Version": "2.5"}, true},
+		{"equals", map[string]string{"x-openrtb-version": "2.5"}, true},
+		{"equals", map[string]string{"X-Openrtb-Version": "2.5.1"}, false},
+		{"equals", nil, false},
+		{"object", map[string]string{"X-OpenRTB-Version": "2.6"}, true},
+		{"object", map[string]string{"X-OpenRTB-Version": "2.5"}, false},
+		{"absent", nil, true},
+		{"absent", map[string]string{"X-Debug": "1"}, false},
+		{"absent", map[string]string{"x-debug": ""}, false},
+		{"present", map[string]string{"X-DEBUG": ""}, true},
+		{"present", nil, false},
+		{"regex", map[string]string{"User-Agent": "Smaato/10"}, true},
+		{"regex", map[string]string{"User-Agent": "Smaato/x"}, false},
+	}
+	for _, c := range cases {
+		status, _, body := testQuery(t, server, c.query, "", c.headers)
+		if found := status == http.StatusOK && body == `{"id":"`+c.query+`"}`; found != c.found {
+			t.Errorf("%s %v: got %d %q, expected found %t", c.query, c.headers, status, body, c.found)
+		}
+	}
+
+	// absent with a value contradicts itself
+	if _, err = newHeaderMatcher(map[string]*HeaderPredicate{"X-Debug": {Present: new(bool), Regex: "1"}}); err == nil {
+		t.Error("expected an absent header with a value refused")
+	}
+}
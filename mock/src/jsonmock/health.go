@@ -0,0 +1,107 @@
+package jsonmock
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Path segment after the location of the server answering whether the process is alive
+const HealthSegment = "healthz"
+
+// Path segment after the location of the server answering whether the map is loaded and valid, 503 otherwise
+const ReadySegment = "readyz"
+
+// Liveness of the process
+type Health struct {
+	Alive   bool      `json:"alive"`
+	Started time.Time `json:"started"`
+	Uptime  string    `json:"uptime"`
+}
+
+// Readiness of a mock server: its map loaded, with some valid entry unless a bidder or generator answers, and no reload ongoing or failed
+type Readiness struct {
+	Ready      bool      `json:"ready"`
+	Reloading  bool      `json:"reloading"`
+	Error      string    `json:"error,omitempty"`
+	File       string    `json:"file"`
+	Total      int       `json:"total"`
+	Valid      int       `json:"valid"`
+	Invalid    int       `json:"invalid"`
+	Duplicated int       `json:"duplicated"`
+	LoadedAt   time.Time `json:"loadedAt"`
+	LoadTime   string    `json:"loadTime"`
+}
+
+// Whether the process is alive, since when
+func (s *Server) Health() Health {
+	return s.handler.health()
+}
+
+// Whether the server is ready to answer from its map, along with its counters and how long it took to load
+func (s *Server) Readiness() Readiness {
+	return s.handler.readiness()
+}
+
+func (c *customHandler) health() Health {
+	return Health{Alive: true, Started: c.started, Uptime: time.Since(c.started).String()}
+}
+
+func (c *customHandler) readiness() Readiness {
+
+	loaded := c.current()
+	readiness := Readiness{
+		Reloading:  atomic.LoadInt32(&c.reloading) == 1,
+		Error:      c.reloadError.Load().(string),
+		File:       loaded.report.File,
+		Total:      loaded.report.Total,
+		Valid:      loaded.report.Valid,
+		Invalid:    loaded.report.Invalid,
+		Duplicated: loaded.report.Duplicated,
+		LoadedAt:   loaded.loadedAt,
+		LoadTime:   loaded.loadTime.String(),
+	}
+	readiness.Ready = !readiness.Reloading && len(readiness.Error) == 0 && (!loaded.rrmap.empty() || c.bidder != nil || c.generator != nil)
+	return readiness
+}
+
+// not ready until that reload is over, nor after it if it failed
+func (c *customHandler) reload(load func() error) error {
+
+	atomic.StoreInt32(&c.reloading, 1)
+	defer atomic.StoreInt32(&c.reloading, 0)
+
+	err := load()
+	if err != nil {
+		c.reloadError.Store(err.Error())
+	} else {
+		c.reloadError.Store("")
+	}
+	return err
+}
+
+// health or readiness query right after the location of the server, so business paths ending the same aren't taken over
+func (c *customHandler) healthSegment(path string) string {
+	location := strings.TrimSuffix(c.location, "/")
+	for _, segment := range []string{HealthSegment, ReadySegment} {
+		if path == location+"/"+segment {
+			return segment
+		}
+	}
+	return ""
+}
+
+// json details of that health query, 503 when not ready
+func (c *customHandler) serveHealth(w http.ResponseWriter, segment string) {
+	if segment == HealthSegment {
+		writeJson(w, c.health())
+		return
+	}
+	readiness := c.readiness()
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJsonStatus(w, status, readiness)
+}
@@ -0,0 +1,83 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+// status and decoded json details of a health query
+func testHealth(t *testing.T, url string, details interface{}) int {
+
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if err = json.NewDecoder(res.Body).Decode(details); err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode
+}
+
+func TestServerHealth(t *testing.T) {
+
+	server, err := NewTestServer(Config{Entries: testEntries(), Strict: true, Location: "/testingEnd/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	var health Health
+	if status := testHealth(t, server.URL+"/testingEnd/"+HealthSegment, &health); status != http.StatusOK || !health.Alive {
+		t.Errorf("got %d %+v, expected alive", status, health)
+	}
+	var readiness Readiness
+	if status := testHealth(t, server.URL+"/testingEnd/"+ReadySegment, &readiness); status != http.StatusOK || !readiness.Ready || readiness.Valid != 4 || readiness.LoadedAt.IsZero() {
+		t.Errorf("got %d %+v, expected ready with 4 valid entries", status, readiness)
+	}
+
+	// anywhere else, those are just business paths
+	for _, path := range []string{"/" + ReadySegment, "/testingEnd/other/" + ReadySegment, "/testingEnd" + ReadySegment} {
+		res, err := http.Get(server.URL + path + "?id=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK || string(content) != `{"id":1}` {
+			t.Errorf("%s: got %d %q, expected the answer of entry 0", path, res.StatusCode, content)
+		}
+	}
+
+	// HEAD is just a GET without body, not a ping
+	res, err := http.Head(server.QueryURL() + "id=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != JsonContentType {
+		t.Errorf("got %d %q, expected the answer of entry 0", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+
+	// a failed reload keeps serving the previous map, but it's not ready
+	if _, err = server.Mock.AddEntries([]Entry{{Query: "id=1", Res: map[string]interface{}{"id": 3}}}); err == nil {
+		t.Fatal("expected a duplicated entry refused in strict mode")
+	}
+	readiness = Readiness{}
+	if status := testHealth(t, server.URL+"/testingEnd/"+ReadySegment, &readiness); status != http.StatusServiceUnavailable || readiness.Ready || len(readiness.Error) == 0 {
+		t.Errorf("got %d %+v, expected not ready after a failed reload", status, readiness)
+	}
+	if status, _, _ := testQuery(t, server, "id=1", "", nil); status != http.StatusOK {
+		t.Errorf("got %d, expected the previous map still served", status)
+	}
+
+	if _, err = server.Mock.AddEntries([]Entry{{Query: "id=9", Res: map[string]interface{}{"id": 9}}}); err != nil {
+		t.Fatal(err)
+	}
+	readiness = Readiness{}
+	if status := testHealth(t, server.URL+"/testingEnd/"+ReadySegment, &readiness); status != http.StatusOK || !readiness.Ready || readiness.Valid != 5 {
+		t.Errorf("got %d %+v, expected ready again with 5 valid entries", status, readiness)
+	}
+}
@@ -0,0 +1,336 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Candidate lists shorter than this are just scanned, as fast as any index
+const IndexThreshold = 8
+
+// positions of a candidate list, in priority order, bucketed by the values they require at the field
+// telling most of them apart; the rest of them may match whatever value
+type candidateIndex struct {
+	field    string
+	segments []string // of the json pointer field, for request Json Schemas
+	buckets  map[string][]int
+	rest     []int
+}
+
+// positions that may match a request, in priority order, walked without allocating
+type candidateCursor struct {
+	bucket []int
+	rest   []int
+	next   int
+	count  int // every position up to count when not indexed, -1 otherwise
+}
+
+// index candidates by the field leaving the fewest of them to scan, nil when a plain scan is enough
+func newCandidateIndex(fields []map[string][]string) *candidateIndex {
+
+	if len(fields) < IndexThreshold {
+		return nil
+	}
+	buckets := make(map[string]map[string]int)
+	for _, candidate := range fields {
+		for field, values := range candidate {
+			if buckets[field] == nil {
+				buckets[field] = make(map[string]int)
+			}
+			for _, value := range values {
+				buckets[field][value]++
+			}
+		}
+	}
+
+	// the worst case: every candidate without that field plus the largest bucket
+	best, bestCost := "", len(fields)
+	for field, values := range buckets {
+		cost := len(fields)
+		for _, count := range values {
+			cost -= count
+		}
+		largest := 0
+		for _, count := range values {
+			if count > largest {
+				largest = count
+			}
+		}
+		cost += largest
+		if cost < bestCost || (cost == bestCost && field < best) {
+			best, bestCost = field, cost
+		}
+	}
+	if bestCost == len(fields) {
+		return nil
+	}
+
+	index := &candidateIndex{field: best, buckets: make(map[string][]int)}
+	for position, candidate := range fields {
+		values, found := candidate[best]
+		if !found {
+			index.rest = append(index.rest, position)
+			continue
+		}
+		for _, value := range values {
+			index.buckets[value] = append(index.buckets[value], position)
+		}
+	}
+	return index
+}
+
+// every position when there is no index, otherwise the ones in the bucket of that value and the rest
+func (x *candidateIndex) cursor(count int, value string, found bool) candidateCursor {
+	if x == nil {
+		return candidateCursor{count: count}
+	}
+	cursor := candidateCursor{rest: x.rest, count: -1}
+	if found {
+		cursor.bucket = x.buckets[value]
+	}
+	return cursor
+}
+
+// candidates that may match those query params
+func (x *candidateIndex) queryCursor(count int, params url.Values) candidateCursor {
+	if x == nil {
+		return candidateCursor{count: count}
+	}
+	values, found := params[x.field]
+	return x.cursor(count, valuesKey(values), found)
+}
+
+// candidates that may match that decoded request body
+func (x *candidateIndex) documentCursor(count int, doc interface{}) candidateCursor {
+	if x == nil {
+		return candidateCursor{count: count}
+	}
+	value, found, applicable := fieldKey(doc, x.segments)
+	if !applicable {
+		// properties don't constrain anything but objects
+		return candidateCursor{count: count}
+	}
+	return x.cursor(count, value, found)
+}
+
+// next position, merging that bucket and the rest in priority order
+func (c *candidateCursor) nextPosition() (int, bool) {
+
+	if c.count >= 0 {
+		if c.next < c.count {
+			c.next++
+			return c.next - 1, true
+		}
+		return 0, false
+	}
+	switch {
+	case len(c.bucket) > 0 && (len(c.rest) == 0 || c.bucket[0] < c.rest[0]):
+		position := c.bucket[0]
+		c.bucket = c.bucket[1:]
+		return position, true
+	case len(c.rest) > 0:
+		position := c.rest[0]
+		c.rest = c.rest[1:]
+		return position, true
+	}
+	return 0, false
+}
+
+// sort every candidate list by priority, the first written winning among equals, and index the long ones
+func (m *RequestResponseMap) compile() {
+
+	m.bodyIndexes = make(map[string]*candidateIndex)
+	for body, candidates := range m.bodies {
+		sortCandidates(candidates)
+		if len(candidates) < IndexThreshold {
+			continue
+		}
+		fields := make([]map[string][]string, len(candidates))
+		for i, candidate := range candidates {
+			fields[i] = candidate.query.requiredFields()
+		}
+		if index := newCandidateIndex(fields); index != nil {
+			m.bodyIndexes[body] = index
+		}
+	}
+
+	sortCandidates(m.schemas)
+	fields := make([]map[string][]string, len(m.schemas))
+	for i, candidate := range m.schemas {
+		fields[i] = candidate.discriminators
+	}
+	m.schemaIndex = newCandidateIndex(fields)
+	if m.schemaIndex != nil {
+		m.schemaIndex.segments = pointerSegments(m.schemaIndex.field)
+	}
+
+	sortCandidates(m.defaults)
+}
+
+// stable, so the first written wins among equals
+func sortCandidates(candidates []QueryResponse) {
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].before(candidates[j]) })
+}
+
+// params a query requires, by name, with their values as an index key
+func (q *QueryMatcher) requiredFields() map[string][]string {
+	fields := make(map[string][]string)
+	for name, values := range q.params {
+		if !q.optional[name] {
+			fields[name] = []string{valuesKey(values)}
+		}
+	}
+	return fields
+}
+
+// repeated values no matter their order
+func valuesKey(values []string) string {
+	if len(values) == 1 {
+		return values[0]
+	}
+	sorted := append([]string{}, values...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, "\x00")
+}
+
+// decoded json as Json Schemas validate it, numbers included
+func decodeJson(content string) (interface{}, error) {
+	var doc interface{}
+	dec := json.NewDecoder(strings.NewReader(content))
+	dec.UseNumber()
+	err := dec.Decode(&doc)
+	return doc, err
+}
+
+// document of a Json Schema reference to a file, at its fragment; nil when it cannot be followed
+func referencedDocument(file string, fragment string) interface{} {
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	var doc interface{}
+	if json.Unmarshal(content, &doc) != nil {
+		return nil
+	}
+	for _, segment := range pointerSegments(strings.TrimPrefix(fragment, "#")) {
+		object, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		doc = object[segment]
+	}
+	return doc
+}
+
+// scalar values a request Json Schema requires, by json pointer of their fields, through required properties and allOf
+func schemaDiscriminators(schema interface{}, pointer string, fields map[string][]string) {
+
+	object, ok := schema.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if allOf, ok := object["allOf"].([]interface{}); ok {
+		for _, item := range allOf {
+			schemaDiscriminators(item, pointer, fields)
+		}
+	}
+	properties, _ := object["properties"].(map[string]interface{})
+	required, _ := object["required"].([]interface{})
+	for _, name := range required {
+		name, ok := name.(string)
+		if !ok {
+			continue
+		}
+		property, ok := properties[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		field := pointer + "/" + strings.Replace(strings.Replace(name, "~", "~0", -1), "/", "~1", -1)
+		if enum, ok := property["enum"].([]interface{}); ok {
+			if _, found := fields[field]; !found {
+				if values, scalar := scalarKeys(enum); scalar {
+					fields[field] = values
+				}
+			}
+		}
+		schemaDiscriminators(property, field, fields)
+	}
+}
+
+// distinct keys of scalar values, false when any of them is not a scalar
+func scalarKeys(values []interface{}) ([]string, bool) {
+	keys := []string{}
+	seen := make(map[string]bool)
+	for _, value := range values {
+		key, scalar := scalarKey(value)
+		if !scalar {
+			return nil, false
+		}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys, true
+}
+
+// json scalar as an index key, the same for equal numbers however they are written
+func scalarKey(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "null", true
+	case bool:
+		return "b" + strconv.FormatBool(v), true
+	case string:
+		return "s" + v, true
+	case float64:
+		return "n" + strconv.FormatFloat(v, 'g', -1, 64), true
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return "", false
+		}
+		return "n" + strconv.FormatFloat(f, 'g', -1, 64), true
+	}
+	return "", false
+}
+
+// key of the field at those segments of a decoded document; not applicable when some parent is not an object
+func fieldKey(doc interface{}, segments []string) (string, bool, bool) {
+	for _, segment := range segments {
+		object, ok := doc.(map[string]interface{})
+		if !ok {
+			return "", false, false
+		}
+		if doc, ok = object[segment]; !ok {
+			return "", false, true
+		}
+	}
+	key, scalar := scalarKey(doc)
+	return key, scalar, true
+}
+
+// unescaped segments of a json pointer
+func pointerSegments(pointer string) []string {
+	if len(pointer) == 0 {
+		return nil
+	}
+	segments := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = strings.Replace(strings.Replace(segment, "~1", "/", -1), "~0", "~", -1)
+	}
+	return segments
+}
+
+// the same as schemaErrors without parsing that document again
+func validDocument(candidate QueryResponse, doc interface{}) bool {
+	result, err := candidate.reqSchema.Validate(gojsonschema.NewRawLoader(doc))
+	return err == nil && result.Valid()
+}
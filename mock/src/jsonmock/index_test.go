@@ -0,0 +1,116 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// map of those entries, without any Json Schema file
+func testMap(t testing.TB, entries []Entry) RequestResponseMap {
+
+	config := Config{Entries: entries}.withDefaults()
+	reqJS, resJS, err := loadJsonSchemas(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rrmap, report, err := loadRequestResponseMap(config, reqJS, resJS)
+	if err != nil || !report.Clean() {
+		t.Fatalf("unexpected report %+v: %v", report, err)
+	}
+	return rrmap
+}
+
+func TestIndexedLookup(t *testing.T) {
+
+	entries := []Entry{}
+	for i := 0; i < 20; i++ {
+		id := strconv.Itoa(i % 10)
+		entries = append(entries,
+			Entry{Query: "id=" + id + "&v=" + strconv.Itoa(i), Priority: i % 3, Res: map[string]interface{}{"query": i}},
+			Entry{ReqSchema: json.RawMessage(`{"required": ["site"], "properties": {"site": {"required": ["id"],
+				"properties": {"id": {"enum": [` + id + `, "` + id + `"]}}}, "v": {"enum": [` + strconv.Itoa(i) + `]}}}`),
+				Priority: i % 3, Res: map[string]interface{}{"schema": i}})
+	}
+	entries = append(entries,
+		Entry{Query: "v=1", OptionalParams: []string{"id"}, Priority: 1, Res: map[string]interface{}{"query": "optional"}},
+		Entry{ReqSchema: json.RawMessage(`{"properties": {"v": {"enum": [3]}}}`), Priority: 1, Res: map[string]interface{}{"schema": "any"}})
+
+	indexed := testMap(t, entries)
+	if indexed.bodyIndexes[""] == nil || indexed.bodyIndexes[""].field != "v" || indexed.schemaIndex == nil || indexed.schemaIndex.field != "/site/id" {
+		t.Fatalf("unexpected indexes %+v %+v", indexed.bodyIndexes[""], indexed.schemaIndex)
+	}
+	scanned := indexed
+	scanned.bodyIndexes, scanned.schemaIndex = nil, nil
+
+	requests := []struct {
+		query string
+		body  string
+	}{
+		{"id=3&v=13", ""},
+		{"id=3&v=1", ""},
+		{"v=1", ""},
+		{"id=3", ""},
+		{"", `{"site": {"id": 3}, "v": 13}`},
+		{"", `{"site": {"id": "3"}, "v": 13.0}`},
+		{"", `{"site": {"id": 3}, "v": 3}`},
+		{"", `{"site": 3, "v": 3}`},
+		{"", `{"v": 3}`},
+		{"", `[]`},
+	}
+	for _, request := range requests {
+		params, _ := url.ParseQuery(request.query)
+		expected, expectedFound := scanned.lookup([]byte(request.body), params, http.Header{})
+		got, found := indexed.lookup([]byte(request.body), params, http.Header{})
+		if found != expectedFound || got.index != expected.index {
+			t.Errorf("%q %q: got entry %d (%t), expected entry %d (%t)", request.query, request.body, got.index, found, expected.index, expectedFound)
+		}
+	}
+}
+
+// lookup cost as maps grow, literal bodies, queries sharing the same empty body and request Json Schemas
+func BenchmarkLookup(b *testing.B) {
+
+	for _, size := range []int{100, 1000, 10000, 100000} {
+		last := strconv.Itoa(size - 1)
+
+		literals := make([]Entry, size)
+		queries := make([]Entry, size)
+		for i := range literals {
+			id := strconv.Itoa(i)
+			literals[i] = Entry{Req: json.RawMessage(`{"id": "` + id + `"}`), Res: map[string]interface{}{"id": id}}
+			queries[i] = Entry{Query: "id=" + id, IgnoreParams: []string{"ts"}, Res: map[string]interface{}{"id": id}}
+		}
+		benchmarkLookup(b, "literal/"+strconv.Itoa(size), testMap(b, literals), "", `{"id":"`+last+`"}`)
+		benchmarkLookup(b, "query/"+strconv.Itoa(size), testMap(b, queries), "id="+last+"&ts=1", "")
+
+		// compiling schemas is way slower than validating them, built once before timing any lookup; up to 10k with -short
+		if size > 10000 && testing.Short() {
+			continue
+		}
+		schemas := make([]Entry, size)
+		for i := range schemas {
+			id := strconv.Itoa(i)
+			schemas[i] = Entry{ReqSchema: json.RawMessage(`{"required": ["id"], "properties": {"id": {"enum": ["` + id + `"]}}}`), Res: map[string]interface{}{"id": id}}
+		}
+		benchmarkLookup(b, "schema/"+strconv.Itoa(size), testMap(b, schemas), "", `{"id":"`+last+`","imp":[{"banner":{}}]}`)
+	}
+}
+
+func benchmarkLookup(b *testing.B, name string, rrmap RequestResponseMap, query string, body string) {
+
+	params, _ := url.ParseQuery(query)
+	headers := http.Header{}
+	key := []byte(body)
+	if _, found := rrmap.lookup(key, params, headers); !found {
+		b.Fatalf("%s: %q %q not found", name, query, body)
+	}
+	b.Run(name, func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			rrmap.lookup(key, params, headers)
+		}
+	})
+}
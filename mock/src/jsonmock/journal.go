@@ -0,0 +1,160 @@
+package jsonmock
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Number of recent requests kept at the journal
+const JournalSize = 100
+
+// Longest request body kept at the journal
+const JournalBodySize = 4096
+
+// How every request was answered
+const (
+	MatchEntry     = "entry"
+	MatchDefault   = "default"
+	MatchBidder    = "bidder"
+	MatchGenerated = "generated"
+	MatchFallback  = "fallback"
+	MatchProxy     = "proxy"
+	MatchMiss      = "miss"
+	MatchThrottled = "throttled"
+	MatchRejected  = "rejected"
+)
+
+// Recent request along with how it was answered; Explanation tells which rule won and why, Diff why no entry matched
+type JournalRecord struct {
+	Time        time.Time `json:"time"`
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	Query       string    `json:"query"`
+	Body        string    `json:"body,omitempty"`
+	Status      int       `json:"status"` // 0 when dropped without any answer at all
+	Match       string    `json:"match"`
+	Entry       *int      `json:"entry,omitempty"`
+	Explanation string    `json:"explanation,omitempty"`
+	Diff        []string  `json:"diff,omitempty"`
+}
+
+// Entry being served along with its validation outcome and how many requests it answered
+type Mapping struct {
+	EntryReport
+	Hits int64 `json:"hits"`
+}
+
+// last requests, oldest overwritten first, and hits of every entry
+type journal struct {
+	records []JournalRecord
+	next    int
+	hits    map[int]int64
+	mutex   sync.Mutex
+}
+
+func newJournal() *journal {
+	return &journal{records: make([]JournalRecord, 0, JournalSize), hits: make(map[int]int64)}
+}
+
+func (j *journal) add(record JournalRecord) {
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if record.Entry != nil {
+		j.hits[*record.Entry]++
+	}
+	if len(j.records) < JournalSize {
+		j.records = append(j.records, record)
+		return
+	}
+	j.records[j.next] = record
+	j.next = (j.next + 1) % JournalSize
+}
+
+// newest first
+func (j *journal) recent() []JournalRecord {
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	records := make([]JournalRecord, 0, len(j.records))
+	for i := len(j.records) - 1; i >= 0; i-- {
+		records = append(records, j.records[(j.next+i)%len(j.records)])
+	}
+	return records
+}
+
+func (j *journal) entryHits(index int) int64 {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.hits[index]
+}
+
+// recent requests, newest first, along with the hits of every entry
+func (j *journal) state() ([]JournalRecord, map[int]int64) {
+
+	records := j.recent()
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	hits := make(map[int]int64, len(j.hits))
+	for index, count := range j.hits {
+		hits[index] = count
+	}
+	return records, hits
+}
+
+// carry on from those requests, newest first, and hits
+func (j *journal) restore(records []JournalRecord, hits map[int]int64) {
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if len(records) > JournalSize {
+		records = records[:JournalSize]
+	}
+	j.records = make([]JournalRecord, 0, JournalSize)
+	j.next = 0
+	for i := len(records) - 1; i >= 0; i-- {
+		j.records = append(j.records, records[i])
+	}
+	j.hits = make(map[int]int64, len(hits))
+	for index, count := range hits {
+		j.hits[index] = count
+	}
+}
+
+// response writer remembering its status for the journal
+type journalWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *journalWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *journalWriter) Write(content []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(content)
+}
+
+// dropping requests needs the connection underneath
+func (w *journalWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("Unable to hijack the connection")
+	}
+	return hijacker.Hijack()
+}
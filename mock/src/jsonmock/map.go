@@ -0,0 +1,705 @@
+package jsonmock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Fake request/response entry as a Go value, the same fields as every item at the Mock Request Response File
+type Entry struct {
+	Query          string                      `json:"query,omitempty"`
+	OptionalParams []string                    `json:"optionalParams,omitempty"`
+	IgnoreParams   []string                    `json:"ignoreParams,omitempty"`
+	Headers        map[string]*HeaderPredicate `json:"headers,omitempty"`
+	Req            interface{}                 `json:"req,omitempty"`
+	ReqSchema      interface{}                 `json:"reqSchema,omitempty"`
+	Priority       int                         `json:"priority,omitempty"`
+	Default        bool                        `json:"default,omitempty"`
+	Res            interface{}                 `json:"res,omitempty"`
+	ResponseBody
+}
+
+// lookup key prefix of the entries matching by request Json Schema, never a compacted json body
+const reqSchemaKey = "reqSchema="
+
+// lookup key of the entries answering whatever no other entry matched
+const defaultKey = "default"
+
+// rate limiting key prefix of the responses no entry provided, one bucket per source as synthetic=bidder
+const syntheticKey = "synthetic="
+
+type QueryResponse struct {
+	key            string
+	index          int
+	query          *QueryMatcher
+	headers        HeaderMatcher
+	reqSchema      *gojsonschema.Schema
+	discriminators map[string][]string // scalar values its request Json Schema requires, by json pointer
+	priority       int
+	isDefault      bool
+	explanation    string
+	response       string
+	contentType    string
+	// written as they are, with no conversion at all
+	content             []byte
+	contentLengthHeader []string
+	contentTypeHeader   []string
+}
+
+// Request Response map: compacted request body -> candidates told apart by their query and headers,
+// along with the candidates matching any body valid against their own request Json Schema
+// and the default ones answering whatever else; long candidate lists get indexed once loaded
+type RequestResponseMap struct {
+	bodies      map[string][]QueryResponse
+	bodyIndexes map[string]*candidateIndex
+	schemas     []QueryResponse
+	schemaIndex *candidateIndex
+	defaults    []QueryResponse
+}
+
+// helper to load entries from several sources into the same map
+type mapLoader struct {
+	config   Config
+	reqJS    *gojsonschema.Schema
+	resJS    *gojsonschema.Schema
+	store    *gojsonschema.SchemaLoader
+	rrmap    RequestResponseMap
+	report   ValidationReport
+	keyOwner map[string]int
+	index    int
+	entries  []SnapshotEntry
+	started  time.Time
+}
+
+// map being served along with its report and every entry as it was loaded, swapped as a whole
+type loadedMap struct {
+	rrmap    RequestResponseMap
+	report   ValidationReport
+	entries  []SnapshotEntry
+	loadedAt time.Time
+	loadTime time.Duration
+}
+
+// validate fake request response map, from its file and Go values, against their json schemas
+func loadRequestResponseMap(config Config, reqJsonSchema *gojsonschema.Schema, resJsonSchema *gojsonschema.Schema) (RequestResponseMap, ValidationReport, error) {
+	loaded, err := loadMap(config, reqJsonSchema, resJsonSchema)
+	return loaded.rrmap, loaded.report, err
+}
+
+func newMapLoader(config Config, reqJsonSchema *gojsonschema.Schema, resJsonSchema *gojsonschema.Schema, file string) *mapLoader {
+	return &mapLoader{
+		config:   config,
+		reqJS:    reqJsonSchema,
+		resJS:    resJsonSchema,
+		rrmap:    RequestResponseMap{bodies: make(map[string][]QueryResponse)},
+		report:   ValidationReport{File: file, Entries: []EntryReport{}},
+		keyOwner: make(map[string]int), // first entry index that provided every key
+		entries:  []SnapshotEntry{},
+		started:  time.Now(),
+	}
+}
+
+// the same as loadRequestResponseMap, keeping every entry as it was loaded
+func loadMap(config Config, reqJsonSchema *gojsonschema.Schema, resJsonSchema *gojsonschema.Schema) (*loadedMap, error) {
+
+	loader := newMapLoader(config, reqJsonSchema, resJsonSchema, config.MapFile)
+
+	if len(config.MapFile) > 0 {
+		mock, err := ioutil.ReadFile(config.MapFile)
+		if err != nil {
+			log.Println(err)
+			return loader.loaded(), errors.New("Unable to read Mock Request Response File.")
+		}
+		if err = loader.load(mock, filepath.Dir(config.MapFile), config.MapFile); err != nil {
+			return loader.loaded(), err
+		}
+	}
+
+	// Go values come after the file ones, relative body files from the working folder
+	if len(config.Entries) > 0 {
+		if len(loader.report.File) == 0 {
+			loader.report.File = EntriesFile
+		}
+		mock, err := json.Marshal(config.Entries)
+		if err != nil {
+			return loader.loaded(), err
+		}
+		if err = loader.load(mock, ".", EntriesFile); err != nil {
+			return loader.loaded(), err
+		}
+	}
+
+	return loader.finish()
+}
+
+// load again entries kept by a previous load, every one of them at its source and base folder
+func loadSnapshotEntries(config Config, reqJsonSchema *gojsonschema.Schema, resJsonSchema *gojsonschema.Schema, file string, entries []SnapshotEntry) (*loadedMap, error) {
+
+	loader := newMapLoader(config, reqJsonSchema, resJsonSchema, file)
+
+	// consecutive entries of the same source are loaded at once
+	for first := 0; first < len(entries); {
+		last := first + 1
+		for last < len(entries) && entries[last].Source == entries[first].Source && entries[last].BaseDir == entries[first].BaseDir {
+			last++
+		}
+		raws := make([]json.RawMessage, 0, last-first)
+		for _, entry := range entries[first:last] {
+			raws = append(raws, entry.Entry)
+		}
+		mock, err := json.Marshal(raws)
+		if err != nil {
+			return loader.loaded(), err
+		}
+		if err = loader.load(mock, entries[first].BaseDir, entries[first].Source); err != nil {
+			return loader.loaded(), err
+		}
+		first = last
+	}
+
+	return loader.finish()
+}
+
+func (l *mapLoader) loaded() *loadedMap {
+	now := time.Now()
+	return &loadedMap{rrmap: l.rrmap, report: l.report, entries: l.entries, loadedAt: now, loadTime: now.Sub(l.started)}
+}
+
+// index the map once every entry is there; empty only when something else answers requests
+func (l *mapLoader) finish() (*loadedMap, error) {
+	l.rrmap.compile()
+	var err error
+	if l.rrmap.empty() && !l.config.answersWithoutEntries() {
+		err = errors.New("Unable to validate any entry at Mock Request Response File")
+	}
+	return l.loaded(), err
+}
+
+// validate and add every entry of a json array, reported as coming from that source
+func (l *mapLoader) load(mock []byte, baseDir string, source string) error {
+
+	err := validateMockInput(mock)
+	if err != nil {
+		return err
+	}
+
+	type ReqRes struct {
+		Qry      string                      `json:"query,omitempty"`
+		Optional []string                    `json:"optionalParams,omitempty"`
+		Ignore   []string                    `json:"ignoreParams,omitempty"`
+		Headers  map[string]*HeaderPredicate `json:"headers,omitempty"`
+		Req      *json.RawMessage            `json:"req,omitempty"`
+		Schema   *json.RawMessage            `json:"reqSchema,omitempty"`
+		Priority int                         `json:"priority,omitempty"`
+		Default  bool                        `json:"default,omitempty"`
+		Res      *json.RawMessage            `json:"res"`
+		ResponseBody
+		request  string
+		response string
+	}
+	dec := json.NewDecoder(bytes.NewReader(mock))
+
+	err = ignoreFirstBracket(dec)
+	if err != nil {
+		return err
+	}
+
+	// entries are kept as they were written, their base folder no matter the working one
+	absDir, err := filepath.Abs(baseDir)
+	if err != nil {
+		return err
+	}
+
+	// read object {"req": string, "res": string}
+	for ; dec.More(); l.index++ {
+		var raw json.RawMessage
+		var rr ReqRes
+		err = dec.Decode(&raw)
+		if err == nil {
+			err = json.Unmarshal(raw, &rr)
+		}
+		if err != nil {
+			log.Println(err)
+			return errors.New("Unable to process object at Mock Request Response File")
+		}
+		l.entries = append(l.entries, SnapshotEntry{Source: source, BaseDir: absDir, Entry: raw})
+		index := l.index
+		entry := EntryReport{Index: index, Source: source, Status: EntryInvalid}
+
+		rr.request, err = toString(rr.Req)
+		if err != nil {
+			log.Println("Unable to process request object at Mock Request Response File")
+			l.report.add(entry.fail("request: " + err.Error()))
+			continue
+		}
+
+		rr.response, err = toString(rr.Res)
+		if err != nil {
+			log.Println("Unable to process response object at Mock Request Response File")
+			l.report.add(entry.fail("response: " + err.Error()))
+			continue
+		}
+
+		// non-json responses carry their own content type
+		contentType := JsonContentType
+		if rr.Res == nil {
+			rr.response, contentType, err = rr.ResponseBody.load(baseDir)
+			if err != nil {
+				log.Println("Unable to process response body at Mock Request Response File")
+				l.report.add(entry.fail("response: " + err.Error()))
+				continue
+			}
+		} else if len(rr.ContentType) > 0 {
+			contentType = rr.ContentType
+		}
+
+		query, err := newQueryMatcher(rr.Qry, rr.Optional, rr.Ignore, l.config.DebugParameter)
+		if err != nil {
+			log.Println("Unable to process query at Mock Request Response File")
+			l.report.add(entry.fail("query: " + err.Error()))
+			continue
+		}
+		headers, err := newHeaderMatcher(rr.Headers)
+		if err != nil {
+			log.Println("Unable to process headers at Mock Request Response File")
+			l.report.add(entry.fail("headers: " + err.Error()))
+			continue
+		}
+		if l.config.ForcedDebug {
+			log.Printf("%v <%v> %v -> %v\n", query, headers, rr.request, rr.response)
+		}
+
+		// a whole class of requests instead of a literal one
+		var reqSchema *gojsonschema.Schema
+		var reqSchemaDoc interface{}
+		schemaKey := ""
+		if rr.Schema != nil {
+			if rr.Req != nil {
+				l.report.add(entry.fail("reqSchema: not allowed along with req"))
+				continue
+			}
+			reqSchema, reqSchemaDoc, err = l.entrySchema(*rr.Schema, baseDir, source, index)
+			if err == nil {
+				schemaKey, err = compactJson(*rr.Schema)
+			}
+			if err != nil {
+				log.Println("Unable to process request Json Schema at Mock Request Response File")
+				l.report.add(entry.fail("reqSchema: " + err.Error()))
+				continue
+			}
+			schemaKey = reqSchemaKey + schemaKey
+		}
+		if rr.Default {
+			if rr.Req != nil || rr.Schema != nil || len(rr.Qry) > 0 {
+				l.report.add(entry.fail("default: not allowed along with req, reqSchema or query"))
+				continue
+			}
+			schemaKey = defaultKey
+		}
+
+		// request could be empty because it's an optative field
+		if len(rr.request) > 0 {
+			for _, desc := range schemaErrors(l.reqJS, rr.request) {
+				entry.fail("request: " + desc)
+			}
+		}
+		if rr.Res != nil {
+			for _, desc := range schemaErrors(l.resJS, rr.response) {
+				entry.fail("response: " + desc)
+			}
+		} else {
+			for _, desc := range rr.ResponseBody.validate(rr.response) {
+				entry.fail("response: " + desc)
+			}
+		}
+		if len(entry.Errors) > 0 {
+			log.Printf("Entry %d is not valid and will be ignored. See errors: \n", index)
+			for _, desc := range entry.Errors {
+				log.Printf("- %s\n", desc)
+			}
+			l.report.add(entry)
+			continue
+		}
+
+		body, err := bodyKey(rr.request)
+		if err != nil {
+			log.Println("This request will be ignored")
+			l.report.add(entry.fail("request: " + err.Error()))
+			continue
+		}
+		key := entryKey(query.String(), headers.String(), body+schemaKey)
+		entry.Key = key
+
+		response := rr.response
+		if rr.Res != nil {
+			response, err = compactJson([]byte(rr.response))
+			if err != nil {
+				log.Println("That response will be ignored")
+				l.report.add(entry.fail("response: " + err.Error()))
+				continue
+			}
+		}
+
+		// first entry wins, the rest are just reported
+		if owner, found := l.keyOwner[key]; found {
+			entry.Status = EntryDuplicated
+			entry.fail("same key as entry " + strconv.Itoa(owner))
+			log.Printf("Entry %d duplicates the key of entry %d and will be ignored\n", index, owner)
+			l.report.add(entry)
+			continue
+		}
+		l.keyOwner[key] = index
+
+		value := newQueryResponse(response, contentType)
+		value.key = key
+		value.index = index
+		value.query = query
+		value.headers = headers
+		value.reqSchema = reqSchema
+		if reqSchema != nil {
+			value.discriminators = make(map[string][]string)
+			schemaDiscriminators(reqSchemaDoc, "", value.discriminators)
+		}
+		value.priority = rr.Priority
+		value.isDefault = rr.Default
+		value.explanation = value.explain()
+		l.rrmap.add(body, value)
+		entry.Status = EntryValid
+		l.report.add(entry)
+	}
+
+	return ignoreLastBracket(dec)
+}
+
+// key into the map for an optional request body
+func bodyKey(request string) (string, error) {
+
+	// request could be empty because it's an optative field
+	if len(request) == 0 {
+		return "", nil
+	}
+
+	// compacting that json to match equivalent requests
+	return compactJson([]byte(request))
+}
+
+// unique description of an entry: its normalized query, its header predicates and its body key
+func entryKey(query string, headers string, body string) string {
+
+	key := body
+	if len(headers) > 0 {
+		// key must take into account as well the header predicates
+		key = "<" + headers + ">" + key
+	}
+	if len(query) > 0 {
+		// key must take into account as well the provided query
+		key = "[" + query + "]" + key
+	}
+	return key
+}
+
+// compile the request Json Schema of an entry, along with its document: a file reference relative to the map,
+// or an inline schema resolved as if it were a sibling file of it
+func (l *mapLoader) entrySchema(raw json.RawMessage, baseDir string, source string, index int) (*gojsonschema.Schema, interface{}, error) {
+
+	if l.store == nil {
+		store, err := NewSchemaStore(l.config.SchemaDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		l.store = store
+	}
+
+	var reference string
+	if json.Unmarshal(raw, &reference) == nil {
+		file, fragment := reference, ""
+		if i := strings.Index(reference, "#"); i >= 0 {
+			file, fragment = reference[:i], reference[i:]
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(baseDir, file)
+		}
+		uri, err := SchemaURI(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		schema, err := l.store.Compile(gojsonschema.NewReferenceLoader(uri + fragment))
+		return schema, referencedDocument(file, fragment), err
+	}
+
+	uri, err := SchemaURI(filepath.Join(baseDir, filepath.Base(source)+".reqSchema"+strconv.Itoa(index)+".json"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = l.store.AddSchema(uri, gojsonschema.NewBytesLoader(raw)); err != nil {
+		return nil, nil, err
+	}
+	var doc interface{}
+	json.Unmarshal(raw, &doc)
+	schema, err := l.store.Compile(gojsonschema.NewReferenceLoader(uri))
+	return schema, doc, err
+}
+
+// no candidate at all
+func (m RequestResponseMap) empty() bool {
+	return len(m.bodies) == 0 && len(m.schemas) == 0 && len(m.defaults) == 0
+}
+
+// response ready to be written
+func newQueryResponse(response string, contentType string) QueryResponse {
+	return QueryResponse{response: response, contentType: contentType, content: []byte(response),
+		contentLengthHeader: []string{strconv.Itoa(len(response))}, contentTypeHeader: []string{contentType}}
+}
+
+// higher priority first, then the one with more header predicates, so the most specific one wins
+func (q QueryResponse) before(other QueryResponse) bool {
+	if q.priority != other.priority {
+		return q.priority > other.priority
+	}
+	return len(q.headers) > len(other.headers)
+}
+
+// append a candidate to its list, to be sorted by priority once compiled
+func (m *RequestResponseMap) add(body string, value QueryResponse) {
+	switch {
+	case value.isDefault:
+		m.defaults = append(m.defaults, value)
+	case value.reqSchema != nil:
+		m.schemas = append(m.schemas, value)
+	default:
+		m.bodies[body] = append(m.bodies[body], value)
+	}
+}
+
+// whether some candidate has exactly that compacted request body
+func (m RequestResponseMap) hasBody(key []byte) bool {
+	_, found := m.bodies[string(key)]
+	return found
+}
+
+// first candidate whose query and headers match: the exact compacted request body, then request Json Schemas
+// and the default entries at last, each one by priority
+func (m RequestResponseMap) lookup(key []byte, params url.Values, headers http.Header) (QueryResponse, bool) {
+
+	// no string built out of that key just to look it up
+	candidates := m.bodies[string(key)]
+	cursor := m.bodyIndexes[string(key)].queryCursor(len(candidates), params)
+	for position, more := cursor.nextPosition(); more; position, more = cursor.nextPosition() {
+		if candidate := candidates[position]; candidate.query.Match(params) && candidate.headers.Match(headers) {
+			return candidate, true
+		}
+	}
+	if len(key) > 0 && len(m.schemas) > 0 {
+		// parsed just once for every request Json Schema
+		if doc, err := decodeJson(string(key)); err == nil {
+			cursor := m.schemaIndex.documentCursor(len(m.schemas), doc)
+			for position, more := cursor.nextPosition(); more; position, more = cursor.nextPosition() {
+				if candidate := m.schemas[position]; candidate.query.Match(params) && candidate.headers.Match(headers) && validDocument(candidate, doc) {
+					return candidate, true
+				}
+			}
+		}
+	}
+	for _, candidate := range m.defaults {
+		if candidate.headers.Match(headers) {
+			return candidate, true
+		}
+	}
+	return QueryResponse{}, false
+}
+
+// which rule made that candidate win
+func (q QueryResponse) explain() string {
+
+	rule := "exact request body"
+	switch {
+	case q.isDefault:
+		rule = "default entry, no other entry matched"
+	case q.reqSchema != nil:
+		rule = "request body valid against its Json Schema, no exact request body matched"
+	}
+	if len(q.headers) > 0 {
+		rule += ", headers " + q.headers.String()
+	}
+	return "entry " + strconv.Itoa(q.index) + ": " + rule + ", priority " + strconv.Itoa(q.priority)
+}
+
+// why no candidate matched that body, params and headers
+func (m RequestResponseMap) diff(body string, params url.Values, headers http.Header) []string {
+
+	candidates := m.bodies[body]
+	if len(body) > 0 {
+		candidates = append(candidates[:len(candidates):len(candidates)], m.schemas...)
+	}
+	candidates = append(candidates[:len(candidates):len(candidates)], m.defaults...)
+	if len(candidates) == 0 {
+		return []string{"no entry with that request body"}
+	}
+	differences := []string{}
+	for _, candidate := range candidates {
+		reasons := candidate.headers.diff(headers)
+		if !candidate.isDefault {
+			reasons = append(candidate.query.diff(params), reasons...)
+		}
+		if candidate.reqSchema != nil {
+			for _, desc := range schemaErrors(candidate.reqSchema, body) {
+				reasons = append(reasons, "request: "+desc)
+			}
+		}
+		differences = append(differences, "entry "+strconv.Itoa(candidate.index)+": "+strings.Join(reasons, "; "))
+	}
+	return differences
+}
+
+// convert into an string
+func toString(raw *json.RawMessage) (string, error) {
+	if raw != nil {
+		noSoRaw, err := json.Marshal(raw)
+		if err != nil {
+			return "", err
+		}
+		return string(noSoRaw), nil
+	} else {
+		return "", nil
+	}
+}
+
+// compact json to make it easy to look into the map for equivalent keys
+func compactJson(loose []byte) (string, error) {
+
+	compactedBuffer := new(bytes.Buffer)
+	err := json.Compact(compactedBuffer, loose)
+	if err != nil {
+		return "", err
+	}
+	return compactedBuffer.String(), nil
+}
+
+// ignore first bracket when json mock Request Response file is decoded
+func ignoreFirstBracket(dec *json.Decoder) error {
+	_, err := dec.Token()
+	if err != nil {
+		log.Println(err)
+		return errors.New("Unable to process first token at Mock Request Response File")
+	}
+	return nil
+}
+
+// ignore last bracket when json mock Request Response file is decoded
+func ignoreLastBracket(dec *json.Decoder) error {
+	_, err := dec.Token()
+	if err != nil {
+		log.Println(err)
+		return errors.New("Unable to process last token at Mock Request Response File")
+	}
+	return nil
+}
+
+// validate just mock input
+func validateMockInput(mock []byte) error {
+
+	// validate the own mock input
+	mockJsonSchema := gojsonschema.NewStringLoader(`{
+		"$schema": "http://json-schema.org/draft-04/schema#",
+  		"title": "Mock Request Response Json Schema",
+  		"description": "version 0.0.1",
+    	"type": "array",
+    	"items": {
+    		"type": "object",
+    		"properties": {
+      			"req": {
+        			"type": "object"
+      			},
+      			"res": {
+        			"type": "object"
+      		   },
+               "reqSchema": {
+                    "type": [ "object", "string" ]
+               },
+               "priority": {
+                    "type": "integer"
+               },
+               "default": {
+                    "type": "boolean"
+               },
+               "query": {
+                    "type": "string"
+               },
+               "optionalParams": {
+                    "type": "array",
+                    "items": { "type": "string" }
+               },
+               "ignoreParams": {
+                    "type": "array",
+                    "items": { "type": "string" }
+               },
+               "headers": {
+                    "type": "object",
+                    "additionalProperties": {
+                         "oneOf": [
+                              { "type": "string" },
+                              {
+                                   "type": "object",
+                                   "properties": {
+                                        "equals": { "type": "string" },
+                                        "regex": { "type": "string" },
+                                        "present": { "type": "boolean" }
+                                   },
+                                   "additionalProperties": false
+                              }
+                         ]
+                    }
+               },
+               "body": {
+                    "type": "string"
+               },
+               "bodyFile": {
+                    "type": "string"
+               },
+               "bodyBase64": {
+                    "type": "string"
+               },
+               "contentType": {
+                    "type": "string"
+               },
+               "format": {
+                    "enum": [ "text", "xml", "vast" ]
+               }
+             },
+    		"oneOf": [
+      			{ "required": [ "res" ] },
+      			{ "required": [ "body" ] },
+      			{ "required": [ "bodyFile" ] },
+      			{ "required": [ "bodyBase64" ] }
+    		]
+  		}
+	}`)
+
+	result, err := gojsonschema.Validate(mockJsonSchema, gojsonschema.NewBytesLoader(mock))
+	if err != nil {
+		log.Println(err)
+		return errors.New("Unable to process mock Json Schema")
+	}
+
+	if !result.Valid() {
+		log.Println("Mock Request Response File is not valid. See errors: ")
+		for _, desc := range result.Errors() {
+			log.Printf("- %s\n", desc)
+		}
+		return errors.New("Invalid Mock Request Response File")
+	}
+
+	// success
+	return nil
+}
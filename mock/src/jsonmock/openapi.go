@@ -0,0 +1,421 @@
+package jsonmock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Json Schema draft understood by the validator
+const JsonSchemaDraft = "http://json-schema.org/draft-04/schema#"
+
+// http methods of an OpenAPI path item, in the order they are imported
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// characters not allowed at generated names
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// Operation of an OpenAPI document along with its self-contained request and response Json Schemas
+type ImportedOperation struct {
+	Id             string
+	Method         string
+	Path           string
+	RequestSchema  json.RawMessage
+	ResponseSchema json.RawMessage
+	Entries        []Entry
+}
+
+// Endpoint at the static prefix shared by some operations; its schemas accept any of theirs
+type ImportedEndpoint struct {
+	Name           string
+	Prefix         string
+	RequestSchema  json.RawMessage
+	ResponseSchema json.RawMessage
+	Operations     []ImportedOperation
+}
+
+// Mock built from an OpenAPI document, along with what couldn't be imported
+type OpenAPIImport struct {
+	Endpoints []ImportedEndpoint
+	Warnings  []string
+}
+
+// helper to walk an OpenAPI document
+type openAPIDocument struct {
+	doc        map[string]interface{}
+	components map[string]interface{}
+}
+
+// Import an OpenAPI 3 json document: Json Schemas per operation, one endpoint per static path prefix and stub entries from its examples
+func ImportOpenAPI(content []byte) (*OpenAPIImport, error) {
+
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber() // examples just as they are written
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if version, _ := doc["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		return nil, errors.New("Unable to import anything but OpenAPI 3 documents")
+	}
+
+	api := &openAPIDocument{doc: doc}
+	if components, ok := doc["components"].(map[string]interface{}); ok {
+		if schemas, ok := components["schemas"].(map[string]interface{}); ok {
+			api.components = map[string]interface{}{"schemas": toJsonSchema(schemas)}
+		}
+	}
+
+	paths, _ := doc["paths"].(map[string]interface{})
+	names := make([]string, 0, len(paths))
+	for path := range paths {
+		names = append(names, path)
+	}
+	sort.Strings(names)
+
+	result := &OpenAPIImport{}
+	byPrefix := make(map[string]int)
+	for _, path := range names {
+		item := api.resolve(paths[path])
+		for _, method := range openAPIMethods {
+			operation, found := item[method].(map[string]interface{})
+			if !found {
+				continue
+			}
+			imported, warnings := api.operation(path, method, item, operation)
+			result.Warnings = append(result.Warnings, warnings...)
+
+			prefix := staticPrefix(path)
+			index, found := byPrefix[prefix]
+			if !found {
+				index = len(result.Endpoints)
+				byPrefix[prefix] = index
+				result.Endpoints = append(result.Endpoints, ImportedEndpoint{Name: endpointName(prefix), Prefix: prefix})
+			}
+			result.Endpoints[index].Operations = append(result.Endpoints[index].Operations, imported)
+		}
+	}
+
+	for i := range result.Endpoints {
+		endpoint := &result.Endpoints[i]
+		var requests, responses []json.RawMessage
+		for _, operation := range endpoint.Operations {
+			if operation.RequestSchema != nil {
+				requests = append(requests, operation.RequestSchema)
+			}
+			if operation.ResponseSchema != nil {
+				responses = append(responses, operation.ResponseSchema)
+			}
+		}
+		var err error
+		if endpoint.RequestSchema, err = api.anyOf(requests); err != nil {
+			return nil, err
+		}
+		if endpoint.ResponseSchema, err = api.anyOf(responses); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// schemas and stub entries of a single operation
+func (api *openAPIDocument) operation(path string, method string, item map[string]interface{}, operation map[string]interface{}) (ImportedOperation, []string) {
+
+	imported := ImportedOperation{Method: strings.ToUpper(method), Path: path}
+	imported.Id, _ = operation["operationId"].(string)
+	if len(imported.Id) == 0 {
+		imported.Id = method + path
+	}
+	imported.Id = strings.Trim(unsafeName.ReplaceAllString(imported.Id, "_"), "_")
+	where := imported.Method + " " + path
+	warnings := []string{}
+
+	var requestExamples []namedExample
+	if body := api.resolve(operation["requestBody"]); body != nil {
+		if media := jsonMedia(api.resolve(body["content"])); media != nil {
+			schema, err := api.schema(media["schema"])
+			if err != nil {
+				warnings = append(warnings, where+": request: "+err.Error())
+			}
+			imported.RequestSchema = schema
+			requestExamples = api.examples(media)
+		}
+	}
+
+	var responseExamples []namedExample
+	if response := api.successResponse(api.resolve(operation["responses"])); response != nil {
+		if media := jsonMedia(api.resolve(response["content"])); media != nil {
+			schema, err := api.schema(media["schema"])
+			if err != nil {
+				warnings = append(warnings, where+": response: "+err.Error())
+			}
+			imported.ResponseSchema = schema
+			responseExamples = api.examples(media)
+		}
+	}
+	if len(responseExamples) == 0 {
+		return imported, append(warnings, where+": no json response example, so no stub entry")
+	}
+
+	query := api.queryExample(item, operation)
+	if len(requestExamples) == 0 {
+		requestExamples = []namedExample{{}}
+	}
+	for _, request := range requestExamples {
+		// responses paired by example name, otherwise the first one
+		response := responseExamples[0]
+		for _, candidate := range responseExamples {
+			if len(request.name) > 0 && candidate.name == request.name {
+				response = candidate
+			}
+		}
+		entry := Entry{Query: query, Res: response.value}
+		if request.value != nil {
+			entry.Req = request.value
+		}
+		imported.Entries = append(imported.Entries, entry)
+	}
+	return imported, warnings
+}
+
+// example value along with its name, if any
+type namedExample struct {
+	name  string
+	value json.RawMessage
+}
+
+// every example of a media type, named ones in order
+func (api *openAPIDocument) examples(media map[string]interface{}) []namedExample {
+
+	result := []namedExample{}
+	if examples, ok := media["examples"].(map[string]interface{}); ok {
+		names := make([]string, 0, len(examples))
+		for name := range examples {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			example := api.resolve(examples[name])
+			if value, found := example["value"]; found {
+				if content, err := json.Marshal(value); err == nil {
+					result = append(result, namedExample{name: name, value: content})
+				}
+			}
+		}
+	}
+	if value, found := media["example"]; found && len(result) == 0 {
+		if content, err := json.Marshal(value); err == nil {
+			result = append(result, namedExample{value: content})
+		}
+	}
+	return result
+}
+
+// query with the example of every query parameter, the ones at the operation over the ones at its path
+func (api *openAPIDocument) queryExample(item map[string]interface{}, operation map[string]interface{}) string {
+
+	params := url.Values{}
+	for _, parameters := range []interface{}{item["parameters"], operation["parameters"]} {
+		list, _ := parameters.([]interface{})
+		for _, raw := range list {
+			parameter := api.resolve(raw)
+			name, _ := parameter["name"].(string)
+			if in, _ := parameter["in"].(string); in != "query" || len(name) == 0 {
+				continue
+			}
+			value, found := parameter["example"]
+			if !found {
+				if examples, ok := parameter["examples"].(map[string]interface{}); ok && len(examples) > 0 {
+					names := make([]string, 0, len(examples))
+					for name := range examples {
+						names = append(names, name)
+					}
+					sort.Strings(names)
+					value, found = api.resolve(examples[names[0]])["value"]
+				}
+			}
+			if !found {
+				if schema := api.resolve(parameter["schema"]); schema != nil {
+					value, found = schema["example"]
+				}
+			}
+			params.Del(name)
+			if found {
+				params[name] = queryValues(value)
+			}
+		}
+	}
+	return params.Encode()
+}
+
+// example as query values, arrays as repeated params
+func queryValues(value interface{}) []string {
+	switch value := value.(type) {
+	case []interface{}:
+		values := []string{}
+		for _, item := range value {
+			values = append(values, queryValues(item)...)
+		}
+		return values
+	case string:
+		return []string{value}
+	default:
+		return []string{fmt.Sprint(value)}
+	}
+}
+
+// first 2xx response, "default" otherwise
+func (api *openAPIDocument) successResponse(responses map[string]interface{}) map[string]interface{} {
+
+	codes := make([]string, 0, len(responses))
+	for code := range responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if strings.HasPrefix(code, "2") {
+			return api.resolve(responses[code])
+		}
+	}
+	return api.resolve(responses["default"])
+}
+
+// json media type of some content, as application/json or application/vnd.api+json
+func jsonMedia(content map[string]interface{}) map[string]interface{} {
+
+	types := make([]string, 0, len(content))
+	for name := range content {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+	for _, name := range types {
+		if isJsonMimeType(name) {
+			media, _ := content[name].(map[string]interface{})
+			if media == nil {
+				media = map[string]interface{}{}
+			}
+			return media
+		}
+	}
+	return nil
+}
+
+// self-contained Json Schema: the OpenAPI one along with every component it could refer to
+func (api *openAPIDocument) schema(schema interface{}) (json.RawMessage, error) {
+
+	result := map[string]interface{}{"$schema": JsonSchemaDraft}
+	if schema != nil {
+		result["allOf"] = []interface{}{toJsonSchema(schema)}
+	}
+	if api.components != nil {
+		result["components"] = api.components
+	}
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		// an empty schema at least
+		return json.RawMessage(`{"$schema": "` + JsonSchemaDraft + `"}`), err
+	}
+	return content, nil
+}
+
+// a schema accepting any of those self-contained ones, anything when none
+func (api *openAPIDocument) anyOf(schemas []json.RawMessage) (json.RawMessage, error) {
+
+	switch len(schemas) {
+	case 0:
+		return api.schema(nil)
+	case 1:
+		return schemas[0], nil
+	}
+	alternatives := []interface{}{}
+	for _, schema := range schemas {
+		var alternative map[string]interface{}
+		if err := json.Unmarshal(schema, &alternative); err != nil {
+			return nil, err
+		}
+		delete(alternative, "$schema")
+		delete(alternative, "components")
+		alternatives = append(alternatives, alternative)
+	}
+	result := map[string]interface{}{"$schema": JsonSchemaDraft, "anyOf": alternatives}
+	if api.components != nil {
+		result["components"] = api.components
+	}
+	return json.MarshalIndent(result, "", "  ")
+}
+
+// follow local $ref, as #/components/responses/NotFound, up to an object
+func (api *openAPIDocument) resolve(value interface{}) map[string]interface{} {
+
+	for hops := 0; hops < 16; hops++ {
+		object, _ := value.(map[string]interface{})
+		ref, isRef := object["$ref"].(string)
+		if !isRef {
+			return object
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			return nil
+		}
+		value = interface{}(api.doc)
+		for _, token := range strings.Split(ref[2:], "/") {
+			token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+			parent, _ := value.(map[string]interface{})
+			value = parent[token]
+		}
+	}
+	return nil
+}
+
+// OpenAPI 3.0 schema into draft-04: nullable types become a list with "null"
+func toJsonSchema(value interface{}) interface{} {
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for name, item := range value {
+			result[name] = toJsonSchema(item)
+		}
+		if nullable, _ := value["nullable"].(bool); nullable {
+			delete(result, "nullable")
+			if kind, ok := value["type"].(string); ok {
+				result["type"] = []interface{}{kind, "null"}
+			}
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, item := range value {
+			result[i] = toJsonSchema(item)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// path up to its first templated segment, as /users for /users/{id}/orders
+func staticPrefix(path string) string {
+
+	segments := []string{}
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		if len(segment) == 0 || strings.Contains(segment, "{") {
+			break
+		}
+		segments = append(segments, segment)
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// endpoint name out of its prefix
+func endpointName(prefix string) string {
+	name := strings.Trim(unsafeName.ReplaceAllString(strings.Trim(prefix, "/"), "_"), "_")
+	if len(name) == 0 {
+		return "root"
+	}
+	return name
+}
@@ -0,0 +1,94 @@
+package jsonmock
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testOpenAPI = `{
+  "openapi": "3.0.1",
+  "paths": {
+    "/ads": {
+      "post": {
+        "operationId": "createAd",
+        "requestBody": { "content": { "application/json": {
+          "schema": { "$ref": "#/components/schemas/Ad" },
+          "examples": { "banner": { "value": { "format": "banner", "price": 1.5 } },
+                        "video": { "$ref": "#/components/examples/video" } } } } },
+        "responses": { "201": { "content": { "application/json": {
+          "schema": { "$ref": "#/components/schemas/Created" },
+          "examples": { "banner": { "value": { "id": "b1" } }, "video": { "value": { "id": "v1" } } } } } } }
+      }
+    },
+    "/ads/{id}": {
+      "parameters": [ { "name": "id", "in": "path", "required": true, "schema": { "type": "string" } } ],
+      "get": {
+        "parameters": [ { "$ref": "#/components/parameters/fields" } ],
+        "responses": { "200": { "$ref": "#/components/responses/Ad" } }
+      }
+    },
+    "/health": {
+      "get": { "responses": { "200": { "content": { "text/plain": {} } } } }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Ad": { "type": "object", "required": [ "format" ],
+              "properties": { "format": { "type": "string" }, "price": { "type": "number", "nullable": true } } },
+      "Created": { "type": "object", "required": [ "id" ], "properties": { "id": { "type": "string" } } }
+    },
+    "parameters": { "fields": { "name": "fields", "in": "query", "example": [ "format", "price" ] } },
+    "examples": { "video": { "value": { "format": "video", "price": null } } },
+    "responses": { "Ad": { "content": { "application/json": {
+      "schema": { "$ref": "#/components/schemas/Ad" }, "example": { "format": "banner", "price": 2 } } } } }
+  }
+}`
+
+func TestImportOpenAPI(t *testing.T) {
+
+	imported, err := ImportOpenAPI([]byte(testOpenAPI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported.Endpoints) != 2 || imported.Endpoints[0].Prefix != "/ads" || imported.Endpoints[1].Name != "health" {
+		t.Fatalf("unexpected endpoints %+v", imported.Endpoints)
+	}
+	if len(imported.Warnings) != 1 || !strings.HasPrefix(imported.Warnings[0], "GET /health") {
+		t.Errorf("unexpected warnings %v", imported.Warnings)
+	}
+
+	ads := imported.Endpoints[0]
+	if len(ads.Operations) != 2 || ads.Operations[0].Id != "createAd" || ads.Operations[1].Id != "get_ads_id" {
+		t.Fatalf("unexpected operations %+v", ads.Operations)
+	}
+	if query := ads.Operations[1].Entries[0].Query; query != "fields=format&fields=price" {
+		t.Errorf("got query %s, expected fields=format&fields=price", query)
+	}
+
+	// the stub entries of every operation are valid against the endpoint schemas
+	dir, err := ioutil.TempDir("", "openapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := Config{RequestSchemaFile: filepath.Join(dir, "request.json"), ResponseSchemaFile: filepath.Join(dir, "response.json")}
+	if err = ioutil.WriteFile(config.RequestSchemaFile, ads.RequestSchema, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(config.ResponseSchemaFile, ads.ResponseSchema, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, operation := range ads.Operations {
+		config.Entries = append(config.Entries, operation.Entries...)
+	}
+	report, err := Validate(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid != 3 || !report.Clean() {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
@@ -0,0 +1,171 @@
+package jsonmock
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Normalized query model: decoded params, repeated values and flags
+type QueryMatcher struct {
+	params   url.Values
+	optional map[string]bool
+	ignore   map[string]bool
+	debug    string
+}
+
+// parse the query of an entry; optional params may be absent, ignored ones are never taken into account
+func newQueryMatcher(query string, optional []string, ignore []string, debugParameter string) (*QueryMatcher, error) {
+
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	matcher := &QueryMatcher{params: params, optional: make(map[string]bool), ignore: make(map[string]bool), debug: debugParameter}
+	matcher.ignore[debugParameter] = true
+	for _, name := range ignore {
+		matcher.ignore[name] = true
+	}
+	for _, name := range optional {
+		matcher.optional[name] = true
+	}
+	for name := range matcher.ignore {
+		params.Del(name)
+	}
+	return matcher, nil
+}
+
+// check out the decoded params of a request
+func (q *QueryMatcher) Match(params url.Values) bool {
+
+	for name, values := range params {
+		if q.ignore[name] {
+			continue
+		}
+		expected, found := q.params[name]
+		if !found || !sameValues(expected, values) {
+			return false
+		}
+	}
+
+	for name := range q.params {
+		if _, found := params[name]; !found && !q.optional[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// why those params don't match, nothing when they do
+func (q *QueryMatcher) diff(params url.Values) []string {
+
+	differences := []string{}
+	for _, name := range sortedValueNames(params) {
+		if q.ignore[name] {
+			continue
+		}
+		expected, found := q.params[name]
+		if !found {
+			differences = append(differences, "unexpected param "+name)
+		} else if !sameValues(expected, params[name]) {
+			differences = append(differences, "param "+name+": expected "+strings.Join(expected, ",")+", got "+strings.Join(params[name], ","))
+		}
+	}
+	for _, name := range sortedValueNames(q.params) {
+		if _, found := params[name]; !found && !q.optional[name] {
+			differences = append(differences, "missing param "+name)
+		}
+	}
+	return differences
+}
+
+// canonical representation, used as part of the lookup key
+func (q *QueryMatcher) String() string {
+
+	result := normalizeQuery(q.params, nil)
+	if names := sortedNames(q.optional); len(names) > 0 {
+		result += ";optional=" + strings.Join(names, ",")
+	}
+	ignored := make(map[string]bool)
+	for name := range q.ignore {
+		if name != q.debug {
+			ignored[name] = true
+		}
+	}
+	if names := sortedNames(ignored); len(names) > 0 {
+		result += ";ignore=" + strings.Join(names, ",")
+	}
+	return result
+}
+
+// ordered and escaped params, repeated ones one after another and flags without '='
+func normalizeQuery(params url.Values, ignore map[string]bool) string {
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		if ignore[name] {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names) // supposed short lists than don't care to be ordered in memory
+
+	result := ""
+	for _, name := range names {
+		values := append([]string{}, params[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			if len(result) > 0 {
+				result += "&"
+			}
+			result += url.QueryEscape(name)
+			if len(value) > 0 {
+				result += "=" + url.QueryEscape(value)
+			}
+		}
+	}
+	return result
+}
+
+// same values no matter their order
+func sameValues(expected []string, got []string) bool {
+
+	if len(expected) != len(got) {
+		return false
+	}
+	if len(got) == 1 {
+		// the usual case, without copying anything
+		return expected[0] == got[0]
+	}
+	a := append([]string{}, expected...)
+	b := append([]string{}, got...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ordered names of a set
+func sortedNames(set map[string]bool) []string {
+	names := []string{}
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ordered names of some params
+func sortedValueNames(params url.Values) []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
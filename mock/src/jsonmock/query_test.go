@@ -0,0 +1,47 @@
+package jsonmock
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestQueryMatcher(t *testing.T) {
+
+	matcher, err := newQueryMatcher("name=a%20b&tag=x&tag=y&page=1", []string{"page"}, []string{"ts"}, DefaultDebugParameter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		query string
+		match bool
+	}{
+		{"name=a%20b&tag=x&tag=y&page=1", true},
+		{"name=a+b&tag=x&tag=y&page=1", true},
+		{"tag=y&page=1&name=a%20b&tag=x", true},
+		{"name=a%20b&tag=y&tag=x", true},
+		{"name=a%20b&tag=x&tag=y&page=2", false},
+		{"name=a%20b&tag=x&tag=y&ts=1700000000", true},
+		{"name=a%20b&tag=x&tag=y&" + DefaultDebugParameter, true},
+		{"name=a%20b&tag=x", false},
+		{"name=a%20b&tag=x&tag=x", false},
+		{"name=a%20b&tag=x&tag=y&tag=z", false},
+		{"name=a%2520b&tag=x&tag=y", false},
+		{"name=a%20b&tag=x&tag=y&other=1", false},
+		{"tag=x&tag=y&page=1", false},
+	}
+	for _, c := range cases {
+		params, err := url.ParseQuery(c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if match := matcher.Match(params); match != c.match {
+			t.Errorf("%s: got %t, expected %t, diff %v", c.query, match, c.match, matcher.diff(params))
+		}
+		if match := len(matcher.diff(params)) == 0; match != c.match {
+			t.Errorf("%s: got diff %v, expected match %t", c.query, matcher.diff(params), c.match)
+		}
+	}
+	if key := matcher.String(); key != "name=a+b&page=1&tag=x&tag=y;optional=page;ignore=ts" {
+		t.Errorf("got key %s", key)
+	}
+}
@@ -0,0 +1,146 @@
+package jsonmock
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Token buckets shared by every request, one per mapping entry or one per client
+const (
+	RateScopeGlobal = "global"
+	RateScopeEntry  = "entry"
+	RateScopeClient = "client"
+)
+
+// Maximum time a dropped request keeps its client waiting when the connection cannot be closed, as on FastCGI, before a 503
+const RateDropTimeout = 30 * time.Second
+
+// buckets kept before forgetting the full ones, as clients come and go
+const rateMaxBuckets = 10000
+
+// tokens refilled at some rate up to the burst
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Throttles requests as SSPs do with bidders: 429 and Retry-After, or no answer at all
+type RateLimiter struct {
+	rate         float64
+	burst        float64
+	scope        string
+	clientHeader string
+	drop         bool
+	now          func() time.Time
+	buckets      map[string]*tokenBucket
+	bucketsMutex sync.Mutex
+	stopping     chan struct{} // closed at shutdown, so that no dropped request holds the drain
+	stopOnce     sync.Once
+}
+
+// requests per second with a burst, at one of the scopes; clients by that header or by remote address
+func newRateLimiter(rate float64, burst int, scope string, clientHeader string, drop bool) (*RateLimiter, error) {
+
+	if rate <= 0 {
+		return nil, errors.New("Rate limit must be greater than 0 requests per second")
+	}
+	if burst < 0 {
+		return nil, errors.New("Rate burst cannot be negative")
+	}
+	if burst == 0 {
+		burst = int(math.Ceil(rate))
+	}
+	switch scope {
+	case RateScopeGlobal, RateScopeEntry, RateScopeClient:
+	default:
+		return nil, errors.New("Unknown rate scope " + scope + ", only " + RateScopeGlobal + ", " + RateScopeEntry + " and " + RateScopeClient + " are supported")
+	}
+	return &RateLimiter{rate: rate, burst: float64(burst), scope: scope, clientHeader: clientHeader, drop: drop,
+		now: time.Now, buckets: make(map[string]*tokenBucket), stopping: make(chan struct{})}, nil
+}
+
+// bucket of that request at a global or client scope; entry scope needs its matched entry
+func (l *RateLimiter) requestKey(r *http.Request) string {
+
+	if l.scope != RateScopeClient {
+		return ""
+	}
+	if len(l.clientHeader) > 0 {
+		return r.Header.Get(l.clientHeader)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// take a token from that bucket, otherwise the time until there will be one
+func (l *RateLimiter) allow(key string) (bool, time.Duration) {
+
+	l.bucketsMutex.Lock()
+	defer l.bucketsMutex.Unlock()
+
+	now := l.now()
+	bucket, found := l.buckets[key]
+	if !found {
+		if len(l.buckets) >= rateMaxBuckets {
+			l.forgetFull(now)
+		}
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+}
+
+// buckets already refilled are just like new ones
+func (l *RateLimiter) forgetFull(now time.Time) {
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// answer a request over the limit
+func (l *RateLimiter) reject(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+
+	if !l.drop {
+		// Retry-After in whole seconds, never 0
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(math.Max(retryAfter.Seconds(), 1)))))
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+
+	if hijacker, ok := w.(http.Hijacker); ok {
+		if conn, _, err := hijacker.Hijack(); err == nil {
+			conn.Close()
+			return
+		}
+	}
+	// no connection to close: keep the client waiting until it gives up, then tell it was dropped rather than answer
+	select {
+	case <-r.Context().Done():
+	case <-l.stopping:
+	case <-time.After(RateDropTimeout):
+	}
+	w.Header().Set("Connection", "close")
+	http.Error(w, "dropped over the rate limit", http.StatusServiceUnavailable)
+}
+
+// let the dropped requests still waiting go
+func (l *RateLimiter) stop() {
+	l.stopOnce.Do(func() { close(l.stopping) })
+}
@@ -0,0 +1,173 @@
+package jsonmock
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+
+	limiter, err := newRateLimiter(2, 3, RateScopeGlobal, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(0, 0)
+	limiter.now = func() time.Time { return now }
+
+	// the whole burst, then one token every half a second
+	for i := 0; i < 3; i++ {
+		if allowed, _ := limiter.allow(""); !allowed {
+			t.Fatalf("request %d of the burst refused", i)
+		}
+	}
+	allowed, retryAfter := limiter.allow("")
+	if allowed || retryAfter != 500*time.Millisecond {
+		t.Fatalf("got %t after %v, expected false after 500ms", allowed, retryAfter)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if allowed, _ := limiter.allow(""); !allowed {
+		t.Fatal("a refilled token was refused")
+	}
+
+	for _, invalid := range []struct {
+		rate  float64
+		burst int
+		scope string
+	}{{0, 1, RateScopeGlobal}, {1, -1, RateScopeGlobal}, {1, 1, "imp"}} {
+		if _, err := newRateLimiter(invalid.rate, invalid.burst, invalid.scope, "", false); err == nil {
+			t.Errorf("%+v must fail", invalid)
+		}
+	}
+}
+
+func TestServerRateLimit(t *testing.T) {
+
+	cases := []struct {
+		scope    string
+		queries  []string
+		headers  []string
+		statuses []int
+	}{
+		{RateScopeGlobal, []string{"id=1", "ping", "id=1"}, []string{"a", "b", "c"}, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}},
+		{RateScopeEntry, []string{"id=1", "ping", "id=1", "ping"}, []string{"a", "a", "a", "a"}, []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusOK}},
+		{RateScopeEntry, []string{"id=1", "id=1", "id=1"}, []string{"a", "a", "a"}, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}},
+		{RateScopeClient, []string{"id=1", "id=1", "id=1", "id=1"}, []string{"a", "b", "a", "a"}, []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests}},
+	}
+	for _, c := range cases {
+		server, err := NewTestServer(Config{Entries: testEntries(), RateLimit: 0.01, RateBurst: 2, RateScope: c.scope, RateClientHeader: "X-Client"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, query := range c.queries {
+			req, _ := http.NewRequest(http.MethodGet, server.QueryURL()+query, nil)
+			req.Header.Set("X-Client", c.headers[i])
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != c.statuses[i] {
+				t.Errorf("%s request %d: got %d, expected %d", c.scope, i, res.StatusCode, c.statuses[i])
+			}
+			if res.StatusCode == http.StatusTooManyRequests && res.Header.Get("Retry-After") != "100" {
+				t.Errorf("%s request %d: got Retry-After %q, expected 100", c.scope, i, res.Header.Get("Retry-After"))
+			}
+		}
+		server.Close()
+	}
+
+	// dropped requests get no answer at all
+	server, err := NewTestServer(Config{Entries: testEntries(), RateLimit: 0.01, RateBurst: 1, RateDrop: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	if status, _, _ := testQuery(t, server, "ping", "", nil); status != http.StatusOK {
+		t.Fatalf("got %d, expected 200", status)
+	}
+	if res, err := http.Get(server.QueryURL() + "ping"); err == nil {
+		res.Body.Close()
+		t.Fatalf("got %d, expected a closed connection", res.StatusCode)
+	}
+	// journaled once its handler is over, right after the connection was closed
+	statuses := testThrottled(server.Mock)
+	for wait := 0; len(statuses) == 0 && wait < 100; wait++ {
+		time.Sleep(10 * time.Millisecond)
+		statuses = testThrottled(server.Mock)
+	}
+	// the client retries a GET once on a closed connection
+	for _, status := range statuses {
+		if status != 0 {
+			t.Errorf("got throttled statuses %v, expected every one without any answer", statuses)
+		}
+	}
+
+	// without any connection to close, as on FastCGI, held until the client gives up and then told apart from success
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/?ping", nil).WithContext(ctx)
+	res := httptest.NewRecorder()
+	server.Mock.Handler().ServeHTTP(res, req)
+	if res.Code != http.StatusServiceUnavailable {
+		t.Errorf("got %d, expected 503 once the client gave up", res.Code)
+	}
+
+	// nor longer than the shutdown
+	done := make(chan int, 1)
+	go func() {
+		res := httptest.NewRecorder()
+		server.Mock.Handler().ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/?ping", nil))
+		done <- res.Code
+	}()
+	time.Sleep(20 * time.Millisecond)
+	server.Mock.stop()
+	select {
+	case status := <-done:
+		if status != http.StatusServiceUnavailable {
+			t.Errorf("got %d, expected 503 at shutdown", status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("dropped request still held after shutdown")
+	}
+	statuses = testThrottled(server.Mock)
+	if last := len(statuses) - 1; last < 2 || statuses[last-2] != 0 || statuses[last-1] != http.StatusServiceUnavailable || statuses[last] != http.StatusServiceUnavailable {
+		t.Errorf("got throttled statuses %v, expected the dropped ones recorded apart", statuses)
+	}
+}
+
+// statuses of the throttled requests at the journal, oldest first
+func testThrottled(server *Server) []int {
+	journal := server.Journal()
+	statuses := []int{}
+	for i := len(journal) - 1; i >= 0; i-- {
+		if journal[i].Match == MatchThrottled {
+			statuses = append(statuses, journal[i].Status)
+		}
+	}
+	return statuses
+}
+
+func TestServerRateLimitSynthetic(t *testing.T) {
+
+	file, remove := testBidderFile(t, `{"seat": "s", "pricing": {"rule": "fixed", "price": 1}, `+testBidderCreatives+`}`)
+	defer remove()
+	server, err := NewTestServer(Config{Entries: testEntries(), BidderConfigFile: file, RateLimit: 0.01, RateBurst: 1, RateScope: RateScopeEntry})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	// every bid shares the bidder bucket, apart from the one of each entry
+	if status, _, _ := testQuery(t, server, "", `{"id": "r1", "imp": [{"id": "1"}]}`, nil); status != http.StatusOK {
+		t.Fatalf("got %d, expected a first bid", status)
+	}
+	if status, _, _ := testQuery(t, server, "id=1", "", nil); status != http.StatusOK {
+		t.Errorf("got %d, expected an entry not throttled by the bids", status)
+	}
+	if status, _, _ := testQuery(t, server, "", `{"id": "r2", "imp": [{"id": "2"}]}`, nil); status != http.StatusTooManyRequests {
+		t.Errorf("got %d, expected another bid throttled", status)
+	}
+}
@@ -0,0 +1,90 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// Status of every entry at the Mock Request Response File
+const (
+	EntryValid      = "valid"
+	EntryInvalid    = "invalid"
+	EntryDuplicated = "duplicated"
+)
+
+// validation outcome of a single entry
+type EntryReport struct {
+	Index  int      `json:"index"`
+	Source string   `json:"source,omitempty"`
+	Status string   `json:"status"`
+	Key    string   `json:"key,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// validation outcome of the whole Mock Request Response File
+type ValidationReport struct {
+	File       string        `json:"file"`
+	Total      int           `json:"total"`
+	Valid      int           `json:"valid"`
+	Invalid    int           `json:"invalid"`
+	Duplicated int           `json:"duplicated"`
+	Entries    []EntryReport `json:"entries"`
+}
+
+// append an error to that entry
+func (e *EntryReport) fail(desc string) EntryReport {
+	e.Errors = append(e.Errors, desc)
+	return *e
+}
+
+// append an entry to the report keeping its counters up to date
+func (r *ValidationReport) add(entry EntryReport) {
+	switch entry.Status {
+	case EntryValid:
+		r.Valid++
+	case EntryDuplicated:
+		r.Duplicated++
+	default:
+		r.Invalid++
+	}
+	r.Total++
+	r.Entries = append(r.Entries, entry)
+}
+
+// any invalid or duplicated entry
+func (r *ValidationReport) Clean() bool {
+	return r.Invalid == 0 && r.Duplicated == 0
+}
+
+// Map that cannot be served, along with the validation outcome of every entry
+type ValidationError struct {
+	Report ValidationReport
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Reason
+}
+
+// Write the report as json; "-" means standard output
+func WriteReport(report ValidationReport, reportFile string) error {
+
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	content = append(content, '\n')
+
+	if reportFile == "-" {
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+	return ioutil.WriteFile(reportFile, content, 0644)
+}
+
+// Summary of the report at the logs
+func LogReport(report ValidationReport) {
+	log.Printf("Entries at %s: %d total, %d valid, %d invalid, %d duplicated", report.File, report.Total, report.Valid, report.Invalid, report.Duplicated)
+}
@@ -0,0 +1,148 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// anything is valid when no Json Schema file is provided
+const permissiveJsonSchema = `{}`
+
+// File URI to be used as base for relative $ref
+func SchemaURI(schemaFile string) (string, error) {
+
+	abs, err := filepath.Abs(schemaFile)
+	if err != nil {
+		return "", err
+	}
+	abs = filepath.ToSlash(abs)
+	if !strings.HasPrefix(abs, "/") {
+		// windows drive letters
+		abs = "/" + abs
+	}
+	return "file://" + abs, nil
+}
+
+// Preload every Json Schema at a folder so cross-file $ref get resolved offline
+func NewSchemaStore(schemaDir string) (*gojsonschema.SchemaLoader, error) {
+
+	store := gojsonschema.NewSchemaLoader()
+	if len(schemaDir) == 0 {
+		return store, nil
+	}
+	files, err := ioutil.ReadDir(schemaDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		schemaFile := filepath.Join(schemaDir, f.Name())
+
+		// only real schemas, not data files, at the same folder
+		content, err := ioutil.ReadFile(schemaFile)
+		if err != nil {
+			return nil, err
+		}
+		var doc map[string]*json.RawMessage
+		if json.Unmarshal(content, &doc) != nil || doc["$schema"] == nil {
+			continue
+		}
+
+		uri, err := SchemaURI(schemaFile)
+		if err != nil {
+			return nil, err
+		}
+		if err = store.AddSchema(uri, gojsonschema.NewBytesLoader(content)); err != nil {
+			return nil, errors.New(schemaFile + ": " + err.Error())
+		}
+	}
+	return store, nil
+}
+
+// Compile a Json Schema embedded at some data file as if it were a sibling file of it, named after that id
+func LoadInlineSchema(store *gojsonschema.SchemaLoader, filename string, id string, schemaStr string) (*gojsonschema.Schema, error) {
+
+	uri, err := SchemaURI(filename + "." + id + ".json")
+	if err != nil {
+		return nil, err
+	}
+	if err = store.AddSchema(uri, gojsonschema.NewStringLoader(schemaStr)); err != nil {
+		return nil, err
+	}
+	return store.Compile(gojsonschema.NewReferenceLoader(uri))
+}
+
+// compile a Json Schema file using its own path as base URI; a permissive one when there is no file
+func loadJsonSchema(store *gojsonschema.SchemaLoader, schemaFile string) (*gojsonschema.Schema, error) {
+
+	if len(schemaFile) == 0 {
+		return gojsonschema.NewSchema(gojsonschema.NewStringLoader(permissiveJsonSchema))
+	}
+	uri, err := SchemaURI(schemaFile)
+	if err != nil {
+		return nil, err
+	}
+	return store.Compile(gojsonschema.NewReferenceLoader(uri))
+}
+
+// request and response Json Schemas of a configuration
+func loadJsonSchemas(config Config) (*gojsonschema.Schema, *gojsonschema.Schema, error) {
+
+	// every schema at the same folder can be referenced through relative $ref
+	store, err := NewSchemaStore(config.SchemaDir)
+	if err != nil {
+		log.Println(err)
+		return nil, nil, errors.New("Unable to preload Json Schema folder.")
+	}
+
+	reqJsonSchema, err := loadJsonSchema(store, config.RequestSchemaFile)
+	if err != nil {
+		log.Println(err)
+		return nil, nil, errors.New("Unable to load Request Json Schema File.")
+	}
+
+	resJsonSchema, err := loadJsonSchema(store, config.ResponseSchemaFile)
+	if err != nil {
+		log.Println(err)
+		return nil, nil, errors.New("Unable to load Response Json Schema File.")
+	}
+	return reqJsonSchema, resJsonSchema, nil
+}
+
+// json schema errors of a document, none when valid
+func schemaErrors(jsonSchema *gojsonschema.Schema, doc string) []string {
+
+	result, err := jsonSchema.Validate(gojsonschema.NewStringLoader(doc))
+	if err != nil {
+		return []string{err.Error()}
+	}
+	errs := []string{}
+	for _, desc := range result.Errors() {
+		errs = append(errs, desc.String())
+	}
+	return errs
+}
+
+// validation request
+func validateRequest(reqJsonSchema *gojsonschema.Schema, rrReq string) bool {
+
+	errs := schemaErrors(reqJsonSchema, rrReq)
+	if len(errs) > 0 {
+		log.Println("Request is not valid. See errors: ")
+		for _, desc := range errs {
+			log.Printf("- %s\n", desc)
+		}
+		log.Println("That request will be ignored")
+		return false
+	}
+	return true
+}
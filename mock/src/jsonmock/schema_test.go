@@ -0,0 +1,66 @@
+package jsonmock
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSchemaStore(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "schemas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"common.json": `{"$schema": "http://json-schema.org/draft-04/schema#", "type": "object", "required": ["id"],
+			"properties": {"id": {"type": "string"}}, "definitions": {"imp": {"type": "object", "required": ["banner"]}}}`,
+		"request.json": `{"$schema": "http://json-schema.org/draft-04/schema#", "type": "object", "required": ["site"],
+			"properties": {"site": {"$ref": "common.json"}, "imp": {"type": "array", "items": {"$ref": "common.json#/definitions/imp"}}}}`,
+		// data files and broken ones at the same folder are skipped, not refused
+		"requestResponseMap.json": `[{"req": {"site": {"id": "1"}}, "res": {}}]`,
+		"notes.json":              `{"description": "no $schema at all"`,
+	}
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reqJS, _, err := loadJsonSchemas(Config{RequestSchemaFile: filepath.Join(dir, "request.json"), SchemaDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		doc   string
+		valid bool
+	}{
+		{`{"site": {"id": "1"}, "imp": [{"banner": {}}]}`, true},
+		{`{"site": {"id": 1}}`, false},
+		{`{"site": {}}`, false},
+		{`{"site": {"id": "1"}, "imp": [{"video": {}}]}`, false},
+	}
+	for _, c := range cases {
+		if errs := schemaErrors(reqJS, c.doc); (len(errs) == 0) != c.valid {
+			t.Errorf("%s: got errors %v, expected valid %t", c.doc, errs, c.valid)
+		}
+	}
+
+	// schemas embedded at a data file resolve their $ref as a sibling file of it
+	store, err := NewSchemaStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inline, err := LoadInlineSchema(store, filepath.Join(dir, "requestResponseMap.json"), "0", `{"$ref": "common.json#/definitions/imp"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := schemaErrors(inline, `{"banner": {}}`); len(errs) > 0 {
+		t.Errorf("got errors %v, expected a valid imp", errs)
+	}
+	if errs := schemaErrors(inline, `{}`); len(errs) == 0 {
+		t.Error("expected an imp without banner refused")
+	}
+}
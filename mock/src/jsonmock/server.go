@@ -0,0 +1,575 @@
+package jsonmock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Default file names at the data folder
+const (
+	DefaultDataDir            = "data"
+	DefaultRequestSchemaFile  = "requestJsonSchema.json"
+	DefaultResponseSchemaFile = "responseJsonSchema.json"
+	DefaultMapFile            = "requestResponseMap.json"
+)
+
+// Query parameter that turns on debug mode for a single request
+const DefaultDebugParameter = "debug"
+
+// Largest request body accepted by default, 413 otherwise
+const DefaultMaxBodySize = 1 << 20
+
+// Report file name when entries are just Go values
+const EntriesFile = "entries"
+
+// Everything a mock server needs; empty schema files mean anything is valid
+type Config struct {
+	MapFile             string
+	Entries             []Entry
+	RequestSchemaFile   string
+	ResponseSchemaFile  string
+	SchemaDir           string
+	Strict              bool
+	DebugParameter      string
+	ForcedDebug         bool
+	Generate            string
+	Seed                int64
+	BidderConfigFile    string
+	Callbacks           string
+	CallbackDelay       time.Duration
+	CallbackProbability *float64 // 1 fires every notice, 0 none of them; every one when not given
+	SinkPath            string
+	RateLimit           float64
+	RateBurst           int
+	RateScope           string
+	RateClientHeader    string
+	RateDrop            bool
+	DefaultResponseFile string
+	ProxyURL            string
+	MaxBodySize         int64
+	SnapshotFile        string
+	Location            string // path of the server, its NGINX location, in front of its probes
+}
+
+// Mock server ready to answer queries, no matter the transport
+type Server struct {
+	config     Config
+	handler    *customHandler
+	resJS      *gojsonschema.Schema
+	loadMutex  sync.Mutex // entries added or restored one at a time
+	hooks      []func()
+	hooksMutex sync.Mutex
+}
+
+// helper for HTTP handler queries
+type customHandler struct {
+	cmux             http.Handler
+	loaded           atomic.Value // *loadedMap, swapped when entries are added or restored
+	started          time.Time
+	reloading        int32
+	reloadError      atomic.Value // string, empty unless the last reload failed
+	reqJS            *gojsonschema.Schema
+	generator        *ResponseGenerator
+	generate         string
+	bidder           *Bidder
+	notifier         *Notifier
+	sink             *NotificationSink
+	limiter          *RateLimiter
+	fallback         *QueryResponse
+	proxy            *httputil.ReverseProxy
+	proxyURL         string
+	journal          *journal
+	forcedDebug      int32
+	debugParameter   string
+	debugIgnore      map[string]bool
+	validateRequests bool
+	maxBodySize      int64
+	location         string
+}
+
+// buffers of request bodies and their keys, reused among requests
+var bufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// fill in the blanks
+func (c Config) withDefaults() Config {
+	if len(c.DebugParameter) == 0 {
+		c.DebugParameter = DefaultDebugParameter
+	}
+	if len(c.Generate) == 0 {
+		c.Generate = GenerateOff
+	}
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = DefaultMaxBodySize
+	}
+	if len(c.RateScope) == 0 {
+		c.RateScope = RateScopeGlobal
+	}
+	if c.CallbackProbability == nil {
+		always := 1.0
+		c.CallbackProbability = &always
+	}
+	c.Location = cleanPrefix(c.Location)
+	if len(c.SchemaDir) == 0 && len(c.RequestSchemaFile) > 0 {
+		c.SchemaDir = filepath.Dir(c.RequestSchemaFile)
+	}
+	return c
+}
+
+// a synthetic bidder or generated responses answer even without any entry
+func (c Config) answersWithoutEntries() bool {
+	return len(c.BidderConfigFile) > 0 || (len(c.Generate) > 0 && c.Generate != GenerateOff)
+}
+
+// Validate the map as a server would do at startup, without serving it
+func Validate(config Config) (ValidationReport, error) {
+
+	config = config.withDefaults()
+	reqJS, resJS, err := loadJsonSchemas(config)
+	if err != nil {
+		return ValidationReport{File: config.MapFile, Entries: []EntryReport{}}, err
+	}
+	_, report, err := loadRequestResponseMap(config, reqJS, resJS)
+	return report, err
+}
+
+// Validate the map and build a server; a *ValidationError carries the report when the map cannot be served
+func NewServer(config Config) (*Server, error) {
+
+	config = config.withDefaults()
+	reqJS, resJS, err := loadJsonSchemas(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// a previous state, if any, instead of the map unless the map was edited after it
+	var snapshot *Snapshot
+	if len(config.SnapshotFile) > 0 {
+		if info, err := os.Stat(config.SnapshotFile); err == nil {
+			if mapInfo, err := os.Stat(config.MapFile); len(config.MapFile) > 0 && err == nil && mapInfo.ModTime().After(info.ModTime()) {
+				log.Println("WARNING: Mock Request Response File " + config.MapFile + " is newer than snapshot " + config.SnapshotFile + ", loading it and ignoring that snapshot")
+			} else {
+				restored, err := LoadSnapshot(config.SnapshotFile)
+				if err != nil {
+					return nil, err
+				}
+				snapshot = &restored
+			}
+		}
+	}
+	var loaded *loadedMap
+	if snapshot != nil {
+		loaded, err = loadSnapshotEntries(config, reqJS, resJS, snapshot.File, snapshot.Entries)
+		if len(config.MapFile) > 0 {
+			log.Println("WARNING: Restoring snapshot " + config.SnapshotFile + ", Mock Request Response File " + config.MapFile + " ignored until it is edited again")
+		} else {
+			log.Println("Restoring snapshot " + config.SnapshotFile)
+		}
+	} else {
+		loaded, err = loadMap(config, reqJS, resJS)
+	}
+	if err != nil {
+		return nil, &ValidationError{Report: loaded.report, Reason: err.Error()}
+	}
+	if config.Strict && !loaded.report.Clean() {
+		return nil, &ValidationError{Report: loaded.report, Reason: "Strict mode refuses to start with invalid or duplicated entries at Mock Request Response File"}
+	}
+
+	// fake responses built by a synthetic bidder or from the response Json Schema
+	var generator *ResponseGenerator
+	switch config.Generate {
+	case GenerateOff:
+	case GenerateMiss, GenerateAlways:
+		if len(config.ResponseSchemaFile) == 0 {
+			return nil, errors.New("Unable to generate responses without a Response Json Schema File")
+		}
+		generator, err = newResponseGenerator(config.ResponseSchemaFile, resJS, config.Seed, config.DebugParameter)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Generating responses (%s) with seed %d", config.Generate, config.Seed)
+	default:
+		return nil, errors.New("Unknown generation mode " + config.Generate)
+	}
+
+	var bidder *Bidder
+	if len(config.BidderConfigFile) > 0 {
+		bidder, err = newBidder(config.BidderConfigFile, resJS)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("Bidding as a synthetic OpenRTB bidder configured at " + config.BidderConfigFile)
+	}
+
+	// win and billing notices of served bid responses
+	var notifier *Notifier
+	if len(config.Callbacks) > 0 {
+		notifier, err = newNotifier(config.Callbacks, config.CallbackDelay, *config.CallbackProbability, config.Seed)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Firing %s callbacks after %v with probability %v", config.Callbacks, config.CallbackDelay, *config.CallbackProbability)
+	}
+
+	// win, billing and loss notices received, to be checked by tests
+	var sink *NotificationSink
+	if len(config.SinkPath) > 0 {
+		sink = newNotificationSink(config.SinkPath)
+		log.Println("Recording notifications under " + sink.path)
+	}
+
+	// throttling as some SSPs do
+	var limiter *RateLimiter
+	if config.RateLimit > 0 {
+		limiter, err = newRateLimiter(config.RateLimit, config.RateBurst, config.RateScope, config.RateClientHeader, config.RateDrop)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Limiting to %v requests per second (%s)", config.RateLimit, config.RateScope)
+	}
+
+	// last resort: either a fixed response or the real server
+	var fallback *QueryResponse
+	var proxy *httputil.ReverseProxy
+	if len(config.DefaultResponseFile) > 0 && len(config.ProxyURL) > 0 {
+		return nil, errors.New("Unable to fall back to both a Default Response File and a proxy")
+	}
+	if len(config.DefaultResponseFile) > 0 {
+		fallback, err = loadDefaultResponse(config.DefaultResponseFile, resJS)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("Answering unmatched requests with " + config.DefaultResponseFile)
+	}
+	if len(config.ProxyURL) > 0 {
+		proxy, err = newProxy(config.ProxyURL)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("Proxying unmatched requests to " + config.ProxyURL)
+	}
+
+	mux := mux.NewRouter()
+	// bind cmux to mx(route) and the map to the loaded one
+	handler := &customHandler{cmux: mux, reqJS: reqJS, generator: generator, generate: config.Generate,
+		bidder: bidder, notifier: notifier, sink: sink, limiter: limiter, fallback: fallback, proxy: proxy, proxyURL: config.ProxyURL,
+		journal: newJournal(), started: time.Now(), debugParameter: config.DebugParameter, debugIgnore: map[string]bool{config.DebugParameter: true},
+		validateRequests: len(config.RequestSchemaFile) > 0, maxBodySize: config.MaxBodySize, location: config.Location}
+	if config.ForcedDebug {
+		handler.forcedDebug = 1
+	}
+	handler.loaded.Store(loaded)
+	handler.reloadError.Store("")
+	mux.Path("/").Handler(handler)
+
+	server := &Server{config: config, handler: handler, resJS: resJS}
+	if notifier != nil {
+		server.AtShutdown(notifier.Wait)
+	}
+	if snapshot != nil {
+		server.restoreState(*snapshot)
+	}
+	if len(config.SnapshotFile) > 0 {
+		// written back so the next start carries on from here
+		server.AtShutdown(func() {
+			if err := SaveSnapshot(config.SnapshotFile, server.Snapshot()); err != nil {
+				log.Println(err)
+				return
+			}
+			log.Println("Snapshot written to " + config.SnapshotFile)
+		})
+	}
+	return server, nil
+}
+
+// map being served right now
+func (c *customHandler) current() *loadedMap {
+	return c.loaded.Load().(*loadedMap)
+}
+
+// Handler answering every query, whatever its path, to be mounted on any HTTP or FastCGI server
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// Validation outcome of every entry being served, the ones added at runtime included
+func (s *Server) Report() ValidationReport {
+	return s.handler.current().report
+}
+
+// Entries being served, the invalid and duplicated ones included, with their hits
+func (s *Server) Mappings() []Mapping {
+	report := s.Report()
+	mappings := make([]Mapping, 0, len(report.Entries))
+	for _, entry := range report.Entries {
+		mappings = append(mappings, Mapping{EntryReport: entry, Hits: s.handler.journal.entryHits(entry.Index)})
+	}
+	return mappings
+}
+
+// Last requests received, newest first, with how they were answered
+func (s *Server) Journal() []JournalRecord {
+	return s.handler.journal.recent()
+}
+
+// Whether debug mode is forced for every request
+func (s *Server) ForcedDebug() bool {
+	return atomic.LoadInt32(&s.handler.forcedDebug) == 1
+}
+
+// Force debug mode for every request, or just for the ones with the debug parameter
+func (s *Server) SetForcedDebug(forced bool) {
+	var value int32
+	if forced {
+		value = 1
+	}
+	atomic.StoreInt32(&s.handler.forcedDebug, value)
+}
+
+// Notifications received under the sink path, nil without any
+func (s *Server) Sink() *NotificationSink {
+	return s.handler.sink
+}
+
+// must have at least ServeHTTP(), otherwise you will get this error
+// *customHandler does not implement http.Handler (missing ServeHTTP method)
+func (c *customHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	// GET params decoded, repeated ones included
+	params := r.URL.Query()
+	debug := (params[c.debugParameter] != nil) || atomic.LoadInt32(&c.forcedDebug) == 1
+
+	if c.sink != nil {
+		if rest, found := c.sink.match(requestPath(r), c.location); found {
+			if debug {
+				log.Println("Notification " + r.URL.String())
+			}
+			c.sink.serve(w, r, rest)
+			return
+		}
+	}
+
+	// probes of the process and its map, not business requests
+	if segment := c.healthSegment(requestPath(r)); len(segment) > 0 {
+		c.serveHealth(w, segment)
+		return
+	}
+
+	// every answer from now on ends up at the journal
+	record := JournalRecord{Time: time.Now(), Method: r.Method, Path: requestPath(r), Query: r.URL.RawQuery, Match: MatchRejected}
+	recorder := &journalWriter{ResponseWriter: w}
+	w = recorder
+	defer func() {
+		record.Status = recorder.status
+		if record.Status == 0 && record.Match != MatchThrottled {
+			record.Status = http.StatusOK
+		}
+		c.journal.add(record)
+	}()
+
+	if c.limiter != nil && c.limiter.scope != RateScopeEntry {
+		if allowed, retryAfter := c.limiter.allow(c.limiter.requestKey(r)); !allowed {
+			if debug {
+				log.Printf("Over the rate limit, retry after %v", retryAfter)
+			}
+			record.Match = MatchThrottled
+			c.limiter.reject(w, r, retryAfter)
+			return
+		}
+	}
+
+	if debug {
+		log.Println(normalizeQuery(params, nil))
+	}
+
+	var content []byte
+	if r.ContentLength > c.maxBodySize {
+		http.Error(w, "Body larger than "+strconv.FormatInt(c.maxBodySize, 10)+" bytes", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+
+		// get body request to process, into a buffer reused by later requests, even chunked ones without length
+		buffer := bufferPool.Get().(*bytes.Buffer)
+		buffer.Reset()
+		defer bufferPool.Put(buffer)
+		if _, err := buffer.ReadFrom(http.MaxBytesReader(w, r.Body, c.maxBodySize)); err != nil {
+			if _, tooLarge := err.(*http.MaxBytesError); tooLarge {
+				http.Error(w, "Body larger than "+strconv.FormatInt(c.maxBodySize, 10)+" bytes", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			if debug {
+				log.Println(err)
+			}
+			return
+		}
+		content = buffer.Bytes()
+	}
+	if len(content) > 0 {
+		if len(content) > JournalBodySize {
+			record.Body = string(content[:JournalBodySize])
+		} else {
+			record.Body = string(content)
+		}
+
+		if debug {
+			log.Println("Body received: " + string(content))
+		}
+
+	} else if debug {
+		log.Println("empty request body received")
+	}
+
+	// the same map for the whole request, even if another one gets loaded meanwhile
+	rrmap := &c.current().rrmap
+
+	// compacted to match equivalent requests
+	key := bufferPool.Get().(*bytes.Buffer)
+	key.Reset()
+	defer bufferPool.Put(key)
+	if len(content) > 0 {
+		if err := json.Compact(key, content); err != nil {
+			if debug {
+				log.Println(err)
+			}
+			http.Error(w, "Body Json Request doesn't comply with its expected Json Schema", http.StatusUnprocessableEntity)
+			return
+		}
+
+		// really not needed, no invalid request in our map, so just when it's not one of them; but it's good to provide some feedback to our logs
+		if c.validateRequests && !rrmap.hasBody(key.Bytes()) && !validateRequest(c.reqJS, string(content)) {
+			http.Error(w, "Body Json Request doesn't comply with its expected Json Schema", http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	value, found := QueryResponse{}, false
+	if c.generate != GenerateAlways && !rrmap.empty() {
+		value, found = rrmap.lookup(key.Bytes(), params, r.Header)
+		if found {
+			index := value.index
+			record.Match, record.Entry, record.Explanation = MatchEntry, &index, value.explanation
+			if value.isDefault {
+				record.Match = MatchDefault
+			}
+		}
+	}
+	if !found && c.bidder != nil && len(content) > 0 {
+		record.Match, record.Explanation = MatchBidder, "no entry matched, synthetic bidder"
+		response, err := c.bidder.Bid(string(content))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+		if len(response) == 0 {
+			http.Error(w, "no bid", http.StatusNoContent)
+			if debug {
+				log.Println("no bid")
+			}
+			return
+		}
+		value = newQueryResponse(response, JsonContentType)
+		value.key = syntheticKey + MatchBidder
+		found = true
+		if debug {
+			log.Println("Bid by the synthetic bidder")
+		}
+	}
+	if !found && c.generator != nil {
+		response, err := c.generator.Generate(key.String(), params)
+		if err != nil {
+			log.Println(err)
+		} else {
+			value = newQueryResponse(response, JsonContentType)
+			value.key = syntheticKey + MatchGenerated
+			found = true
+			record.Match, record.Explanation = MatchGenerated, "no entry matched, generated from the response Json Schema"
+			if debug {
+				log.Println("Generated response from its Json Schema")
+			}
+		}
+	}
+
+	if !found && c.fallback != nil {
+		value, found = *c.fallback, true
+		record.Match, record.Explanation = MatchFallback, "no entry matched, default response"
+	}
+	if debug && found {
+		log.Println("Matched " + record.Explanation)
+	}
+
+	if found && c.limiter != nil && c.limiter.scope == RateScopeEntry {
+		if allowed, retryAfter := c.limiter.allow(value.key); !allowed {
+			if debug {
+				log.Printf("Over the rate limit of its entry, retry after %v", retryAfter)
+			}
+			record.Match, record.Entry, record.Explanation = MatchThrottled, nil, ""
+			c.limiter.reject(w, r, retryAfter)
+			return
+		}
+	}
+
+	if found {
+		// prepared once, as every header value
+		header := w.Header()
+		header["Content-Length"] = value.contentLengthHeader
+		header["Content-Type"] = value.contentTypeHeader
+		if _, err := w.Write(value.content); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			if debug {
+				log.Println(err)
+			}
+		}
+		if debug {
+			log.Println("Sent back: " + value.response)
+		}
+		if c.notifier != nil && value.contentType == JsonContentType {
+			c.notifier.Notify(value.response, debug)
+		}
+	} else if c.proxy != nil {
+		record.Match, record.Explanation = MatchProxy, "no entry matched, proxied to "+c.proxyURL
+		if debug {
+			log.Println("Proxying to " + c.proxyURL)
+		}
+		// body already read
+		r.Body = ioutil.NopCloser(bytes.NewReader(content))
+		c.proxy.ServeHTTP(w, r)
+	} else if rrmap.empty() {
+		record.Match = MatchMiss
+		http.Error(w, "no entry at internal cache", http.StatusNoContent)
+		if debug {
+			log.Println("no entry at internal cache")
+		}
+	} else if len(content) == 0 && len(normalizeQuery(params, c.debugIgnore)) == 0 {
+		record.Match = MatchMiss
+		http.Error(w, "empty query with empty request body", http.StatusNoContent)
+		if debug {
+			log.Println("empty query with empty request body")
+		}
+	} else {
+		record.Match, record.Diff = MatchMiss, rrmap.diff(key.String(), params, r.Header)
+		http.Error(w, "key not found at internal cache", http.StatusNoContent)
+		if debug {
+			log.Println("key not found at internal cache")
+		}
+	}
+
+	if debug {
+		log.Printf("Processed request of %d bytes", len(content))
+	}
+}
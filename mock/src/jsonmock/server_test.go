@@ -0,0 +1,369 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// entries as Go values, no file at all
+func testEntries() []Entry {
+	text := "pong"
+	return []Entry{
+		{Query: "id=1", Res: map[string]interface{}{"id": 1}},
+		{Query: "id=1", Headers: map[string]*HeaderPredicate{"X-Version": {Regex: "^2\\."}}, Res: map[string]interface{}{"id": 2}},
+		{Req: json.RawMessage(`{"imp": [ {"id": "a"} ]}`), Res: map[string]interface{}{"seat": "a"}},
+		{Query: "ping", ResponseBody: ResponseBody{Body: &text}},
+	}
+}
+
+// query the test server and return status code, content type and body
+func testQuery(t *testing.T, server *TestServer, query string, body string, headers map[string]string) (int, string, string) {
+
+	method := http.MethodGet
+	if len(body) > 0 {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequest(method, server.QueryURL()+query, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode, res.Header.Get("Content-Type"), string(content)
+}
+
+func TestServerEntries(t *testing.T) {
+
+	server, err := NewTestServer(Config{Entries: testEntries()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	report := server.Mock.Report()
+	if report.File != EntriesFile || report.Valid != 4 || !report.Clean() {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	cases := []struct {
+		query       string
+		body        string
+		headers     map[string]string
+		status      int
+		contentType string
+		response    string
+	}{
+		{"id=1", "", nil, http.StatusOK, JsonContentType, `{"id":1}`},
+		{"id=1&debug", "", nil, http.StatusOK, JsonContentType, `{"id":1}`},
+		{"id=1", "", map[string]string{"X-Version": "2.1"}, http.StatusOK, JsonContentType, `{"id":2}`},
+		{"", `{ "imp": [{"id":"a"}] }`, nil, http.StatusOK, JsonContentType, `{"seat":"a"}`},
+		{"ping", "", nil, http.StatusOK, "text/plain; charset=utf-8", "pong"},
+		{"id=2", "", nil, http.StatusNoContent, "", ""},
+		{"", "", nil, http.StatusNoContent, "", ""},
+	}
+	for _, c := range cases {
+		status, contentType, response := testQuery(t, server, c.query, c.body, c.headers)
+		if status != c.status || response != c.response {
+			t.Errorf("%q %q: got %d %q, expected %d %q", c.query, c.body, status, response, c.status, c.response)
+		}
+		if status == http.StatusOK && contentType != c.contentType {
+			t.Errorf("%q %q: got content type %q, expected %q", c.query, c.body, contentType, c.contentType)
+		}
+	}
+}
+
+func TestServerStrict(t *testing.T) {
+
+	entries := append(testEntries(), Entry{Query: "id=1", Res: map[string]interface{}{"id": 3}})
+
+	server, err := NewServer(Config{Entries: entries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report := server.Report(); report.Duplicated != 1 || report.Entries[4].Status != EntryDuplicated {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	_, err = NewServer(Config{Entries: entries, Strict: true})
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	if validationErr.Report.Valid != 4 || validationErr.Report.Duplicated != 1 {
+		t.Fatalf("unexpected report %+v", validationErr.Report)
+	}
+}
+
+func TestServerNoEntries(t *testing.T) {
+
+	_, err := NewServer(Config{})
+	if _, ok := err.(*ValidationError); !ok {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	if _, err := NewServer(Config{Entries: testEntries(), Generate: GenerateMiss}); err == nil {
+		t.Fatal("generation without a response Json Schema File must fail")
+	}
+}
+
+func TestServerRequestSchemas(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "reqSchema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	video := `{"definitions": {"rewarded": {"required": ["imp"], "properties": {"imp": {"items": {"required": ["video"],
+		"properties": {"video": {"required": ["rewarded"], "properties": {"rewarded": {"enum": [1]}}}}}}}}}}`
+	if err = ioutil.WriteFile(filepath.Join(dir, "video.json"), []byte(video), 0644); err != nil {
+		t.Fatal(err)
+	}
+	banner := json.RawMessage(`{"required": ["imp"], "properties": {"imp": {"items": {"required": ["banner"]}}}}`)
+	mapFile := filepath.Join(dir, "map.json")
+	mock := `[
+		{"reqSchema": "video.json#/definitions/rewarded", "res": {"seat": "rewarded"}},
+		{"reqSchema": ` + string(banner) + `, "res": {"seat": "banner"}},
+		{"reqSchema": ` + string(banner) + `, "headers": {"X-Test": "1"}, "res": {"seat": "test banner"}},
+		{"req": {"imp": [{"id": "a", "banner": {}}]}, "res": {"seat": "a"}},
+		{"reqSchema": "missing.json", "res": {}},
+		{"reqSchema": {}, "req": {}, "res": {}},
+		{"reqSchema": ` + string(banner) + `, "res": {"seat": "again"}}
+	]`
+	if err = ioutil.WriteFile(mapFile, []byte(mock), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := NewTestServer(Config{MapFile: mapFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	if report := server.Mock.Report(); report.Valid != 4 || report.Invalid != 2 || report.Duplicated != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	cases := []struct {
+		body     string
+		headers  map[string]string
+		status   int
+		response string
+	}{
+		{`{"imp": [{"id": "b", "video": {"rewarded": 1}}]}`, nil, http.StatusOK, `{"seat":"rewarded"}`},
+		{`{"imp": [{"id": "b", "banner": {"w": 300}}]}`, nil, http.StatusOK, `{"seat":"banner"}`},
+		{`{"imp": [{"id": "b", "banner": {"w": 300}}]}`, map[string]string{"X-Test": "1"}, http.StatusOK, `{"seat":"test banner"}`},
+		{`{"imp": [{"id": "a", "banner": {}}]}`, map[string]string{"X-Test": "1"}, http.StatusOK, `{"seat":"a"}`},
+		{`{"imp": [{"id": "b", "video": {"rewarded": 0}}]}`, nil, http.StatusNoContent, ""},
+	}
+	for _, c := range cases {
+		status, _, response := testQuery(t, server, "", c.body, c.headers)
+		if status != c.status || response != c.response {
+			t.Errorf("%s: got %d %q, expected %d %q", c.body, status, response, c.status, c.response)
+		}
+	}
+
+	miss := server.Mock.Journal()[0]
+	if len(miss.Diff) != 3 || !strings.Contains(miss.Diff[0], "request: ") {
+		t.Errorf("unexpected diff %v", miss.Diff)
+	}
+}
+
+func TestServerFallbackChain(t *testing.T) {
+
+	present := true
+	rewarded := json.RawMessage(`{"properties": {"rewarded": {"enum": [1]}}}`)
+	entries := []Entry{
+		{Req: json.RawMessage(`{"rewarded": 1}`), Res: map[string]interface{}{"id": "exact"}},
+		{ReqSchema: json.RawMessage(`{}`), Res: map[string]interface{}{"id": "any"}},
+		{ReqSchema: rewarded, Priority: 10, Res: map[string]interface{}{"id": "rewarded"}},
+		{Default: true, Headers: map[string]*HeaderPredicate{"X-Test": {Present: &present}}, Res: map[string]interface{}{"id": "test default"}},
+		{Default: true, Query: "id=1", Res: map[string]interface{}{}},
+	}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.URL.RawQuery + " " + string(body)))
+	}))
+	defer upstream.Close()
+
+	server, err := NewTestServer(Config{Entries: entries, ProxyURL: upstream.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	if report := server.Mock.Report(); report.Valid != 4 || report.Invalid != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	cases := []struct {
+		query    string
+		body     string
+		headers  map[string]string
+		response string
+		match    string
+	}{
+		{"", `{"rewarded": 1}`, nil, `{"id":"exact"}`, "entry 0: exact request body, priority 0"},
+		{"", `{"rewarded": 1, "id": "b"}`, nil, `{"id":"rewarded"}`, "entry 2: request body valid against its Json Schema, no exact request body matched, priority 10"},
+		{"", `{"rewarded": 0}`, nil, `{"id":"any"}`, "entry 1: request body valid against its Json Schema, no exact request body matched, priority 0"},
+		{"id=2", "", map[string]string{"X-Test": "1"}, `{"id":"test default"}`, "entry 3: default entry, no other entry matched, headers X-Test, priority 0"},
+		{"id=2", `{"rewarded": 1}`, nil, `id=2 {"rewarded": 1}`, "no entry matched, proxied to " + upstream.URL},
+	}
+	for _, c := range cases {
+		_, _, response := testQuery(t, server, c.query, c.body, c.headers)
+		record := server.Mock.Journal()[0]
+		if response != c.response || record.Explanation != c.match {
+			t.Errorf("%q %q: got %q (%s), expected %q (%s)", c.query, c.body, response, record.Explanation, c.response, c.match)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "fallback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defaultFile := filepath.Join(dir, "default.json")
+	if err = ioutil.WriteFile(defaultFile, []byte(`{ "id": "none" }`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewServer(Config{Entries: entries, DefaultResponseFile: defaultFile, ProxyURL: upstream.URL}); err == nil {
+		t.Fatal("both a default response and a proxy must fail")
+	}
+	fallback, err := NewTestServer(Config{Entries: testEntries(), DefaultResponseFile: defaultFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fallback.Close()
+	if status, _, response := testQuery(t, fallback, "id=2", "", nil); status != http.StatusOK || response != `{"id":"none"}` {
+		t.Errorf("got %d %q, expected the default response", status, response)
+	}
+	if record := fallback.Mock.Journal()[0]; record.Match != MatchFallback {
+		t.Errorf("unexpected journal record %+v", record)
+	}
+}
+
+// requests answered per second by the handler alone, no network at all
+func BenchmarkServeHTTP(b *testing.B) {
+
+	dir, err := ioutil.TempDir("", "serve")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := Config{RequestSchemaFile: filepath.Join(dir, "request.json")}
+	schema := `{"$schema": "` + JsonSchemaDraft + `", "type": "object", "properties": {"id": {"type": "string"}, "imp": {"type": "array"}}}`
+	if err = ioutil.WriteFile(config.RequestSchemaFile, []byte(schema), 0644); err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		id := strconv.Itoa(i)
+		config.Entries = append(config.Entries,
+			Entry{Req: json.RawMessage(`{"id": "` + id + `", "imp": [{"id": "1", "banner": {"w": 300, "h": 250}}]}`), Res: map[string]interface{}{"id": id, "seatbid": []interface{}{}}},
+			Entry{Query: "id=" + id + "&country=us", IgnoreParams: []string{"ts"}, Res: map[string]interface{}{"id": id}})
+	}
+	server, err := NewServer(config)
+	if err != nil {
+		b.Fatal(err)
+	}
+	handler := server.Handler()
+
+	cases := []struct {
+		name   string
+		query  string
+		body   string
+		status int
+	}{
+		{"body", "", `{ "id": "999", "imp": [ {"id": "1", "banner": {"w": 300, "h": 250}} ] }`, http.StatusOK},
+		{"query", "country=us&id=999&ts=123", "", http.StatusOK},
+		{"miss", "", `{"id": "none"}`, http.StatusNoContent},
+	}
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				method := http.MethodGet
+				if len(c.body) > 0 {
+					method = http.MethodPost
+				}
+				req := httptest.NewRequest(method, "/?"+c.query, strings.NewReader(c.body))
+				res := httptest.NewRecorder()
+				handler.ServeHTTP(res, req)
+				if res.Code != c.status {
+					b.Fatalf("got %d, expected %d", res.Code, c.status)
+				}
+			}
+		})
+	}
+}
+
+func TestServerMaxBodySize(t *testing.T) {
+
+	server, err := NewTestServer(Config{Entries: testEntries(), MaxBodySize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	if status, _, _ := testQuery(t, server, "", `{"imp": [{"id": "a"}]}`, nil); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("got %d, expected %d", status, http.StatusRequestEntityTooLarge)
+	}
+	if status, _, _ := testQuery(t, server, "", `{"imp": []}`, nil); status != http.StatusNoContent {
+		t.Errorf("got %d, expected %d", status, http.StatusNoContent)
+	}
+	if status, _, _ := testQuery(t, server, "", `{"imp": [`, nil); status != http.StatusUnprocessableEntity {
+		t.Errorf("got %d, expected %d", status, http.StatusUnprocessableEntity)
+	}
+
+	// chunked bodies, without any length told upfront as their reader hides it
+	cases := []struct {
+		body   string
+		status int
+	}{
+		{`{"imp": [{"id": "a"}]}`, http.StatusRequestEntityTooLarge},
+		{`{"imp": []}`, http.StatusNoContent},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest(http.MethodPost, server.QueryURL(), ioutil.NopCloser(strings.NewReader(c.body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != c.status {
+			t.Errorf("%s: got %d, expected %d", c.body, res.StatusCode, c.status)
+		}
+	}
+
+	// chunked body matching an entry
+	matching, err := NewTestServer(Config{Entries: testEntries()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer matching.Close()
+	res, err := http.Post(matching.QueryURL(), JsonContentType, ioutil.NopCloser(strings.NewReader(`{"imp": [{"id": "a"}]}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || string(body) != `{"seat":"a"}` {
+		t.Errorf("got %d %q, expected the entry matching a chunked body", res.StatusCode, body)
+	}
+}
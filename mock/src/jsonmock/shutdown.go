@@ -0,0 +1,114 @@
+package jsonmock
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/http/fcgi"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Maximum time to wait for in-flight requests once a shutdown signal is received
+const DefaultDrainTimeout = 10 * time.Second
+
+// helper to know how many requests are still being served
+type drainHandler struct {
+	handler  http.Handler
+	inFlight int64
+}
+
+func (d *drainHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&d.inFlight, 1)
+	defer atomic.AddInt64(&d.inFlight, -1)
+	d.handler.ServeHTTP(w, r)
+}
+
+// wait for in-flight requests to finish, but no more than timeout
+func (d *drainHandler) drain(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for atomic.LoadInt64(&d.inFlight) > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+// Register something to be flushed to disk before exiting (logs, metrics, journal, ...)
+func (s *Server) AtShutdown(hook func()) {
+	s.hooksMutex.Lock()
+	defer s.hooksMutex.Unlock()
+	s.hooks = append(s.hooks, hook)
+}
+
+// run every hook only once, last registered first
+func (s *Server) runShutdownHooks() {
+	s.hooksMutex.Lock()
+	defer s.hooksMutex.Unlock()
+	for i := len(s.hooks) - 1; i >= 0; i-- {
+		s.hooks[i]()
+	}
+	s.hooks = nil
+	os.Stderr.Sync()
+}
+
+// requests held on purpose don't wait any longer, so they don't hold the drain
+func (s *Server) stop() {
+	if s.handler.limiter != nil {
+		s.handler.limiter.stop()
+	}
+}
+
+// Serve FastCGI requests until SIGINT/SIGTERM, then stop accepting and drain in-flight ones
+func (s *Server) ServeFCGI(listener net.Listener, timeout time.Duration) error {
+	return serveFCGI(listener, s.Handler(), timeout, s.stop, s.runShutdownHooks)
+}
+
+// serve FastCGI requests until SIGINT/SIGTERM, stop and drain in-flight ones and run those hooks before returning
+func serveFCGI(listener net.Listener, handler http.Handler, timeout time.Duration, stop func(), runShutdownHooks func()) error {
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	return serveUntil(listener, handler, fcgi.Serve, signals, timeout, stop, runShutdownHooks)
+}
+
+// serve until some signal arrives, then stop accepting, drain in-flight requests up to timeout and run the hooks
+func serveUntil(listener net.Listener, handler http.Handler, serve func(net.Listener, http.Handler) error, signals <-chan os.Signal,
+	timeout time.Duration, stop func(), runShutdownHooks func()) error {
+
+	drain := &drainHandler{handler: handler}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(listener, drain)
+	}()
+
+	select {
+	case err := <-served:
+		// listener broken before any signal
+		runShutdownHooks()
+		return err
+	case sig := <-signals:
+		log.Printf("Received %v: no more connections accepted", sig)
+	}
+
+	listener.Close()
+	<-served
+	stop()
+
+	if drain.drain(timeout) {
+		log.Println("Every in-flight request was served")
+	} else {
+		log.Printf("%d requests still in flight after %v", atomic.LoadInt64(&drain.inFlight), timeout)
+	}
+
+	runShutdownHooks()
+	return nil
+}
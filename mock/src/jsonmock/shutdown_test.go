@@ -0,0 +1,96 @@
+package jsonmock
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestServeUntilDrain(t *testing.T) {
+
+	for _, c := range []struct {
+		name    string
+		timeout time.Duration
+		events  string
+	}{
+		{"drained", time.Second, "started stopped served hooks"},
+		{"timed out", 50 * time.Millisecond, "started stopped hooks served"},
+	} {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// what happened, in order
+		var events []string
+		var eventsMutex sync.Mutex
+		event := func(name string) {
+			eventsMutex.Lock()
+			events = append(events, name)
+			eventsMutex.Unlock()
+		}
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			event("started")
+			close(started)
+			<-release
+			w.Write([]byte("done"))
+			event("served")
+		})
+
+		signals := make(chan os.Signal, 1)
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- serveUntil(listener, handler, http.Serve, signals, c.timeout, func() { event("stopped") }, func() { event("hooks") })
+		}()
+
+		answered := make(chan string, 1)
+		go func() {
+			res, err := http.Get("http://" + listener.Addr().String() + "/")
+			if err != nil {
+				answered <- err.Error()
+				return
+			}
+			content, _ := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			answered <- string(content)
+		}()
+
+		<-started
+		signals <- syscall.SIGTERM
+		if c.timeout == time.Second {
+			// still in flight while draining
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+		}
+		if err = <-stopped; err != nil {
+			t.Fatal(err)
+		}
+		if c.timeout != time.Second {
+			close(release)
+		}
+		if answer := <-answered; answer != "done" {
+			t.Errorf("%s: got %q, expected the in-flight request answered", c.name, answer)
+		}
+
+		eventsMutex.Lock()
+		got := ""
+		for _, name := range events {
+			if len(got) > 0 {
+				got += " "
+			}
+			got += name
+		}
+		eventsMutex.Unlock()
+		if got != c.events {
+			t.Errorf("%s: got %q, expected %q", c.name, got, c.events)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Path segment under the sink to query and reset its hits
+const SinkHitsSegment = "_hits"
+
+// Reserved query param to assert the number of hits: 417 when it doesn't match
+const SinkCountParam = "_count"
+
+// Maximum number of hits kept, the oldest ones are dropped first
+const SinkMaxHits = 100000
+
+// macros the notifier forgot to expand, as ${AUCTION_PRICE}
+var unexpandedMacro = regexp.MustCompile(`\$\{[A-Z_]+\}`)
+
+// Notification received by the sink, as a win, billing or loss notice
+type NotificationHit struct {
+	Kind       string     `json:"kind"`
+	Path       string     `json:"path"`
+	Params     url.Values `json:"params"`
+	Unexpanded []string   `json:"unexpanded,omitempty"`
+	Time       time.Time  `json:"time"`
+}
+
+// Answer of the sink to a hits query
+type NotificationHits struct {
+	Count int               `json:"count"`
+	Hits  []NotificationHit `json:"hits"`
+}
+
+// Records every notification under a path, as /notifications/burl?id=X&price=1.23
+type NotificationSink struct {
+	path      string
+	hits      []NotificationHit
+	hitsMutex sync.Mutex
+}
+
+func newNotificationSink(path string) *NotificationSink {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return &NotificationSink{path: strings.TrimSuffix(path, "/")}
+}
+
+// what follows the sink path right after the location of the server or its endpoint prefix
+func (s *NotificationSink) match(path string, location string) (string, bool) {
+
+	prefix := strings.TrimSuffix(location, "/") + s.path
+	if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+		return "", false
+	}
+	return strings.Trim(path[len(prefix):], "/"), true
+}
+
+// record a notification, or answer a hits query
+func (s *NotificationSink) serve(w http.ResponseWriter, r *http.Request, rest string) {
+
+	if rest == SinkHitsSegment || strings.HasPrefix(rest, SinkHitsSegment+"/") {
+		kind := strings.TrimPrefix(strings.TrimPrefix(rest, SinkHitsSegment), "/")
+		if r.Method == http.MethodDelete {
+			s.Reset()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		s.query(w, kind, r.URL.Query())
+		return
+	}
+
+	params := r.URL.Query()
+	hit := NotificationHit{Kind: rest, Path: requestPath(r), Params: params, Time: time.Now()}
+	for _, values := range params {
+		for _, value := range values {
+			hit.Unexpanded = append(hit.Unexpanded, unexpandedMacro.FindAllString(value, -1)...)
+		}
+	}
+
+	s.hitsMutex.Lock()
+	if len(s.hits) >= SinkMaxHits {
+		s.hits = s.hits[1:]
+	}
+	s.hits = append(s.hits, hit)
+	s.hitsMutex.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// hits matching that filter as json; the status tells whether the expected count holds
+func (s *NotificationSink) query(w http.ResponseWriter, kind string, filter url.Values) {
+
+	status := http.StatusOK
+	expected := filter.Get(SinkCountParam)
+	filter.Del(SinkCountParam)
+
+	result := NotificationHits{Hits: s.Hits(kind, filter)}
+	result.Count = len(result.Hits)
+	if len(expected) > 0 {
+		count, err := strconv.Atoi(expected)
+		if err != nil {
+			http.Error(w, SinkCountParam+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if count != result.Count {
+			status = http.StatusExpectationFailed
+		}
+	}
+
+	content, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", JsonContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.WriteHeader(status)
+	w.Write(content)
+}
+
+// Hits of that kind, every kind when empty, having every param of the filter among their values
+func (s *NotificationSink) Hits(kind string, filter url.Values) []NotificationHit {
+
+	s.hitsMutex.Lock()
+	defer s.hitsMutex.Unlock()
+
+	hits := []NotificationHit{}
+	for _, hit := range s.hits {
+		if len(kind) > 0 && hit.Kind != kind {
+			continue
+		}
+		matched := true
+		for name, values := range filter {
+			for _, value := range values {
+				if !contains(hit.Params[name], value) {
+					matched = false
+				}
+			}
+		}
+		if matched {
+			hits = append(hits, hit)
+		}
+	}
+	return hits
+}
+
+// Forget every hit
+func (s *NotificationSink) Reset() {
+	s.hitsMutex.Lock()
+	defer s.hitsMutex.Unlock()
+	s.hits = nil
+}
+
+// carry on from those hits, oldest first
+func (s *NotificationSink) restore(hits []NotificationHit) {
+	s.hitsMutex.Lock()
+	defer s.hitsMutex.Unlock()
+	if len(hits) > SinkMaxHits {
+		hits = hits[len(hits)-SinkMaxHits:]
+	}
+	s.hits = append([]NotificationHit{}, hits...)
+}
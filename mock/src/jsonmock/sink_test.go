@@ -0,0 +1,113 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestNotificationSink(t *testing.T) {
+
+	// exchange-side sink receiving the notices of another mock
+	sink, err := NewTestServer(Config{Entries: testEntries(), SinkPath: "/notifications"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	response := json.RawMessage(`{"id": "auction-1", "cur": "EUR", "seatbid": [ {"seat": "s1", "bid": [
+		{"id": "1", "impid": "imp-1", "price": 1.23,
+		 "nurl": "` + sink.URL + `/notifications/nurl?id=${AUCTION_ID}&price=${AUCTION_PRICE}",
+		 "burl": "` + sink.URL + `/notifications/burl?id=${AUCTION_ID}&price=${AUCTION_PRICE}&loss=${AUCTION_LOSS}"} ] } ]}`)
+	bidder, err := NewTestServer(Config{
+		Entries:       []Entry{{Query: "auction", Res: response}},
+		Callbacks:     CallbackNurl + "," + CallbackBurl,
+		CallbackDelay: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.Get(bidder.QueryURL() + "auction")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	bidder.Close()
+
+	hits := sink.Mock.Sink().Hits(CallbackBurl, url.Values{"id": {"auction-1"}, "price": {"1.23"}})
+	if len(hits) != 1 {
+		t.Fatalf("got %d burl hits, expected 1", len(hits))
+	}
+	if len(hits[0].Unexpanded) != 1 || hits[0].Unexpanded[0] != "${AUCTION_LOSS}" {
+		t.Errorf("got unexpanded macros %v, expected [${AUCTION_LOSS}]", hits[0].Unexpanded)
+	}
+
+	cases := []struct {
+		query  string
+		status int
+		count  int
+	}{
+		{"/notifications/_hits", http.StatusOK, 2},
+		{"/notifications/_hits/nurl?id=auction-1&_count=1", http.StatusOK, 1},
+		{"/notifications/_hits/burl?id=auction-2&_count=1", http.StatusExpectationFailed, 0},
+	}
+	for _, c := range cases {
+		res, err := http.Get(sink.URL + c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result NotificationHits
+		err = json.NewDecoder(res.Body).Decode(&result)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != c.status || result.Count != c.count || len(result.Hits) != c.count {
+			t.Errorf("%s: got %d with %d hits, expected %d with %d", c.query, res.StatusCode, result.Count, c.status, c.count)
+		}
+	}
+
+	request, _ := http.NewRequest(http.MethodDelete, sink.URL+"/notifications/_hits", nil)
+	if res, err = http.DefaultClient.Do(request); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if hits = sink.Mock.Sink().Hits("", nil); len(hits) != 0 {
+		t.Errorf("got %d hits after reset, expected none", len(hits))
+	}
+}
+
+func TestNotificationSinkLocation(t *testing.T) {
+
+	server, err := NewTestServer(Config{Entries: testEntries(), SinkPath: "/notifications", Location: "/testingEnd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	// right after the location only, anywhere else just business paths served from the map
+	for _, path := range []string{"/testingEnd/notifications/win", "/testingEnd/v2/notifications/win", "/notifications/win"} {
+		res, err := http.Get(server.URL + path + "?id=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		recorded := len(server.Mock.Sink().Hits("win", nil)) == 1
+		if path == "/testingEnd/notifications/win" {
+			if res.StatusCode != http.StatusOK || !recorded {
+				t.Errorf("%s: got %d, expected the notice recorded", path, res.StatusCode)
+			}
+			continue
+		}
+		if res.StatusCode != http.StatusOK || string(content) != `{"id":1}` {
+			t.Errorf("%s: got %d %q, expected the answer of entry 0", path, res.StatusCode, content)
+		}
+	}
+	if hits := server.Mock.Sink().Hits("", nil); len(hits) != 1 {
+		t.Errorf("got %d hits, expected only the one right after the location", len(hits))
+	}
+}
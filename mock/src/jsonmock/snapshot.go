@@ -0,0 +1,182 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Version of the snapshot format written by this mock
+const SnapshotVersion = 1
+
+// Source reported for the entries added at runtime
+const RuntimeSource = "runtime"
+
+// Entry as it was written, along with where it came from, to be loaded again the same way
+type SnapshotEntry struct {
+	Source  string          `json:"source"`
+	BaseDir string          `json:"baseDir"`
+	Entry   json.RawMessage `json:"entry"`
+}
+
+// Whole state of a mock server: every entry with its source, their hits, recent requests and notifications received
+type Snapshot struct {
+	Version       int               `json:"version"`
+	Time          time.Time         `json:"time"`
+	File          string            `json:"file"`
+	ForcedDebug   bool              `json:"forcedDebug"`
+	Entries       []SnapshotEntry   `json:"entries"`
+	Hits          map[int]int64     `json:"hits"`
+	Journal       []JournalRecord   `json:"journal"`
+	Notifications []NotificationHit `json:"notifications,omitempty"`
+}
+
+// Read a snapshot written by SaveSnapshot
+func LoadSnapshot(file string) (Snapshot, error) {
+
+	var snapshot Snapshot
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Println(err)
+		return snapshot, errors.New("Unable to read Snapshot File.")
+	}
+	if err = json.Unmarshal(content, &snapshot); err != nil {
+		log.Println(err)
+		return snapshot, errors.New("Unable to process Snapshot File.")
+	}
+	if snapshot.Version != SnapshotVersion {
+		return snapshot, errors.New("Unable to process Snapshot File of version " + strconv.Itoa(snapshot.Version))
+	}
+	return snapshot, nil
+}
+
+// Write that snapshot as indented json, replacing the file only once completely written
+func SaveSnapshot(file string, snapshot Snapshot) error {
+
+	content, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file))
+	if err != nil {
+		return err
+	}
+	if _, err = temp.Write(content); err == nil {
+		err = temp.Close()
+	} else {
+		temp.Close()
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return os.Rename(temp.Name(), file)
+}
+
+// Current state, to be restored later on by this or any other server
+func (s *Server) Snapshot() Snapshot {
+
+	loaded := s.handler.current()
+	records, hits := s.handler.journal.state()
+	snapshot := Snapshot{
+		Version:     SnapshotVersion,
+		Time:        time.Now(),
+		File:        loaded.report.File,
+		ForcedDebug: s.ForcedDebug(),
+		Entries:     loaded.entries,
+		Hits:        hits,
+		Journal:     records,
+	}
+	if s.handler.sink != nil {
+		snapshot.Notifications = s.handler.sink.Hits("", nil)
+	}
+	return snapshot
+}
+
+// Serve the entries of that snapshot and carry on from its hits, journal and notifications;
+// nothing changes when its entries cannot be served
+func (s *Server) Restore(snapshot Snapshot) error {
+
+	if snapshot.Version != SnapshotVersion {
+		return errors.New("Unable to restore a snapshot of version " + strconv.Itoa(snapshot.Version))
+	}
+
+	s.loadMutex.Lock()
+	defer s.loadMutex.Unlock()
+
+	return s.handler.reload(func() error {
+		loaded, err := s.loadEntries(snapshot.File, snapshot.Entries)
+		if err != nil {
+			return err
+		}
+		s.handler.loaded.Store(loaded)
+		s.restoreState(snapshot)
+		log.Printf("Restored %d entries from a snapshot taken at %v", len(snapshot.Entries), snapshot.Time)
+		return nil
+	})
+}
+
+// everything but the entries
+func (s *Server) restoreState(snapshot Snapshot) {
+	s.handler.journal.restore(snapshot.Journal, snapshot.Hits)
+	s.SetForcedDebug(snapshot.ForcedDebug)
+	if s.handler.sink != nil {
+		s.handler.sink.restore(snapshot.Notifications)
+	}
+}
+
+// Serve those entries too, after the ones already there, relative body files from the working folder;
+// validation outcome of just those entries, nothing added when they cannot be served
+func (s *Server) AddEntries(entries []Entry) ([]EntryReport, error) {
+
+	mock, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	var raws []json.RawMessage
+	if err = json.Unmarshal(mock, &raws); err != nil {
+		return nil, err
+	}
+	baseDir, err := filepath.Abs(".")
+	if err != nil {
+		return nil, err
+	}
+
+	s.loadMutex.Lock()
+	defer s.loadMutex.Unlock()
+
+	current := s.handler.current()
+	all := append([]SnapshotEntry{}, current.entries...)
+	for _, raw := range raws {
+		all = append(all, SnapshotEntry{Source: RuntimeSource, BaseDir: baseDir, Entry: raw})
+	}
+	var added []EntryReport
+	err = s.handler.reload(func() error {
+		loaded, err := s.loadEntries(current.report.File, all)
+		if err != nil {
+			return err
+		}
+		added = loaded.report.Entries[len(current.report.Entries):]
+		s.handler.loaded.Store(loaded)
+		log.Printf("Added %d entries at runtime", len(added))
+		return nil
+	})
+	return added, err
+}
+
+// validate those entries as the ones at startup, strict mode included
+func (s *Server) loadEntries(file string, entries []SnapshotEntry) (*loadedMap, error) {
+	loaded, err := loadSnapshotEntries(s.config, s.handler.reqJS, s.resJS, file, entries)
+	if err != nil {
+		return nil, &ValidationError{Report: loaded.report, Reason: err.Error()}
+	}
+	if s.config.Strict && !loaded.report.Clean() {
+		return nil, &ValidationError{Report: loaded.report, Reason: "Strict mode refuses to serve invalid or duplicated entries"}
+	}
+	return loaded, nil
+}
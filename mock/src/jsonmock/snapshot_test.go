@@ -0,0 +1,134 @@
+package jsonmock
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "snapshot.json")
+
+	config := Config{Entries: testEntries(), SinkPath: "/notifications", SnapshotFile: file}
+	server, err := NewTestServer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added, err := server.Mock.AddEntries([]Entry{
+		{Query: "id=9", Res: map[string]interface{}{"id": 9}},
+		{ReqSchema: json.RawMessage(`{"required": ["site"]}`), Res: map[string]interface{}{"site": true}},
+		{Query: "id=1", Res: map[string]interface{}{"id": 3}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 3 || added[0].Index != 4 || added[0].Source != RuntimeSource || added[0].Status != EntryValid || added[2].Status != EntryDuplicated {
+		t.Fatalf("unexpected added entries %+v", added)
+	}
+	testQuery(t, server, "id=9", "", nil)
+	testQuery(t, server, "id=9", "", nil)
+	testQuery(t, server, "", `{"site": {}}`, nil)
+	testQuery(t, server, "id=1", "", nil)
+	server.Mock.SetForcedDebug(true)
+	res, err := http.Get(server.URL + "/notifications/win?id=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	server.Close()
+
+	// carry on from the snapshot written at shutdown, no entries of its own
+	config.Entries = nil
+	restored, err := NewTestServer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	if status, _, body := testQuery(t, restored, "", `{"site": 1}`, nil); status != http.StatusOK || body != `{"site":true}` {
+		t.Errorf("got %d %q, expected the runtime entry matching by request Json Schema", status, body)
+	}
+	mappings := restored.Mock.Mappings()
+	if len(mappings) != 7 || mappings[4].Source != RuntimeSource || mappings[4].Hits != 2 || mappings[5].Hits != 2 || mappings[0].Hits != 1 {
+		t.Fatalf("unexpected mappings %+v", mappings)
+	}
+	if journal := restored.Mock.Journal(); len(journal) != 5 || journal[1].Query != "id=1" || journal[4].Query != "id=9" {
+		t.Errorf("unexpected journal %+v", journal)
+	}
+	if !restored.Mock.ForcedDebug() || len(restored.Mock.Sink().Hits("win", nil)) != 1 {
+		t.Error("expected forced debug and the win notice restored")
+	}
+
+	// nothing changes when a snapshot cannot be served
+	snapshot := restored.Mock.Snapshot()
+	snapshot.Entries = snapshot.Entries[:0]
+	if err = restored.Mock.Restore(snapshot); err == nil || len(restored.Mock.Mappings()) != 7 {
+		t.Errorf("expected an empty snapshot refused, got %v", err)
+	}
+}
+
+func TestSnapshotOlderThanMap(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mapFile := filepath.Join(dir, "map.json")
+	if err = ioutil.WriteFile(mapFile, []byte(`[{"query": "id=1", "res": {"id": 1}}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := Config{MapFile: mapFile, SnapshotFile: filepath.Join(dir, "snapshot.json")}
+	server, err := NewTestServer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = server.Mock.AddEntries([]Entry{{Query: "id=9", Res: map[string]interface{}{"id": 9}}}); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	// the snapshot written at shutdown instead of the map it came from
+	restored, err := NewTestServer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mappings := restored.Mock.Mappings(); len(mappings) != 2 || mappings[1].Source != RuntimeSource {
+		t.Errorf("expected the runtime entry restored, got %+v", mappings)
+	}
+	restored.Close()
+
+	// but the map once edited after it
+	if err = ioutil.WriteFile(mapFile, []byte(`[{"query": "id=2", "res": {"id": 2}}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(config.SnapshotFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := info.ModTime().Add(time.Second)
+	if err = os.Chtimes(mapFile, edited, edited); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewTestServer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloaded.Close()
+	if mappings := reloaded.Mock.Mappings(); len(mappings) != 1 || mappings[0].Source == RuntimeSource {
+		t.Errorf("expected only the edited map, got %+v", mappings)
+	}
+	if status, _, body := testQuery(t, reloaded, "id=2", "", nil); status != http.StatusOK || body != `{"id":2}` {
+		t.Errorf("got %d %q, expected the entry of the edited map", status, body)
+	}
+}
@@ -0,0 +1,33 @@
+package jsonmock
+
+import (
+	"net/http/httptest"
+)
+
+// Mock server listening on an ephemeral local port through plain HTTP, meant for go test suites
+type TestServer struct {
+	*httptest.Server
+	Mock *Server
+}
+
+// Validate the map and start serving it right away; don't forget to Close it
+func NewTestServer(config Config) (*TestServer, error) {
+
+	mock, err := NewServer(config)
+	if err != nil {
+		return nil, err
+	}
+	return &TestServer{Server: httptest.NewServer(mock.Handler()), Mock: mock}, nil
+}
+
+// Url to append a query to, as the testers' queryStr
+func (t *TestServer) QueryURL() string {
+	return t.URL + "/?"
+}
+
+// Stop listening, wait for in-flight requests and run the shutdown hooks
+func (t *TestServer) Close() {
+	t.Mock.stop()
+	t.Server.Close()
+	t.Mock.runShutdownHooks()
+}